@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// opKind identifies one step of a line-level edit script.
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// diffOp is a single line of an edit script. a and b are 0-based line
+// indices into the old and new inputs; only the index relevant to the
+// op kind is meaningful for deletes and inserts.
+type diffOp struct {
+	kind opKind
+	a, b int
+}
+
+// unifiedDiff renders a unified diff between oldSrc and newSrc, labelling
+// the two sides "a/<path>" and "b/<path>" so the output can be fed to
+// `git apply` or `patch -p1`. It returns an empty string when the inputs
+// are identical.
+//
+// edits, if non-nil, are the edits that turned oldSrc into newSrc. Only
+// the lines they touch are then searched for differences.
+func unifiedDiff(path string, oldSrc, newSrc []byte, edits []transform.Edit, context int) string {
+	if bytes.Equal(oldSrc, newSrc) {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+
+	a := splitLines(oldSrc)
+	b := splitLines(newSrc)
+	ops, ok := diffEdits(a, b, edits)
+	if !ok {
+		ops = diffLines(a, b)
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- a/%s\n", path)
+	fmt.Fprintf(&buf, "+++ b/%s\n", path)
+
+	for _, h := range hunks(ops, context) {
+		writeHunk(&buf, h, a, b)
+	}
+	return buf.String()
+}
+
+// splitLines splits src into lines, keeping the trailing "\n" on each
+// line so that a missing final newline can be reported faithfully.
+func splitLines(src []byte) []string {
+	var lines []string
+	for len(src) > 0 {
+		i := bytes.IndexByte(src, '\n')
+		if i < 0 {
+			lines = append(lines, string(src))
+			break
+		}
+		lines = append(lines, string(src[:i+1]))
+		src = src[i+1:]
+	}
+	return lines
+}
+
+// diffEdits computes a line-level edit script from a to b given the
+// edits between them. Each run of lines touched by edits is diffed on its
+// own, so a file with many scattered edits costs no more than the sum of
+// its small regions. It reports false if there are no edits, or if the
+// lines between the runs do not match up, in which case the whole inputs
+// must be diffed.
+func diffEdits(a, b []string, edits []transform.Edit) ([]diffOp, bool) {
+	if len(edits) == 0 {
+		return nil, false
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	// i and j are the next unmatched lines of a and b; delta is the number
+	// of lines the edits so far have added.
+	i, j, delta := 0, 0, 0
+	equalTo := func(ai, bj int) bool {
+		if ai-i != bj-j || ai > len(a) || bj > len(b) {
+			return false
+		}
+		for ; i < ai; i, j = i+1, j+1 {
+			if a[i] != b[j] {
+				return false
+			}
+			ops = append(ops, diffOp{kind: opEqual, a: i, b: j})
+		}
+		return true
+	}
+
+	for n := 0; n < len(edits); {
+		// Edits that share a line belong to the same run.
+		a0 := int(edits[n].StartPoint.Row)
+		b0 := a0 + delta
+		a1 := a0
+		for ; n < len(edits) && int(edits[n].StartPoint.Row) <= a1; n++ {
+			e := edits[n]
+			if end := int(e.EndPoint.Row); end > a1 {
+				a1 = end
+			}
+			delta += strings.Count(e.Replacement, "\n") - strings.Count(e.Original, "\n")
+		}
+		a1++
+		b1 := a1 + delta
+		if a1 >= len(a) {
+			a1, b1 = len(a), len(b)
+		}
+
+		if !equalTo(a0, b0) || a1 < a0 || b1 < b0 {
+			return nil, false
+		}
+		for _, op := range diffLines(a[a0:a1], b[b0:b1]) {
+			op.a += a0
+			op.b += b0
+			ops = append(ops, op)
+		}
+		i, j = a1, b1
+	}
+	if !equalTo(len(a), len(b)) {
+		return nil, false
+	}
+	return ops, true
+}
+
+// diffLines computes a line-level edit script from a to b. The common
+// prefix and suffix are matched directly so that the Myers search (and
+// its per-step snapshots) only covers the region that actually changed.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: opEqual, a: i, b: i})
+	}
+	for _, op := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		op.a += prefix
+		op.b += prefix
+		ops = append(ops, op)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, diffOp{kind: opEqual, a: len(a) - i, b: len(b) - i})
+	}
+	return ops
+}
+
+// myers computes a shortest edit script from a to b using Myers'
+// O((N+M)D) greedy algorithm. Migrations touch few lines, so D stays
+// small even for very large files.
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] is a snapshot of v[offset-d-1 : offset+d+2] before step
+	// d, the only diagonals step d reads, used for backtracking. Saving
+	// just those keeps the trace at O(D²) rather than O(D×(N+M)).
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the recorded traces from the end of both inputs back
+// to the start, producing the edit script in forward order.
+func backtrack(trace [][]int, a, b []string) []diffOp {
+	x, y := len(a), len(b)
+	var ops []diffOp
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] starts at diagonal -d-1.
+		v, offset := trace[d], d+1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: opEqual, a: x, b: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{kind: opInsert, a: x, b: y})
+		} else {
+			x--
+			ops = append(ops, diffOp{kind: opDelete, a: x, b: y})
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// hunk is a contiguous slice of the edit script, including context.
+type hunk struct {
+	ops []diffOp
+}
+
+// hunks groups the edit script into hunks, surrounding each run of
+// changes with up to context lines of unchanged text and merging runs
+// whose context would overlap.
+func hunks(ops []diffOp, context int) []hunk {
+	var out []hunk
+	i := 0
+	for i < len(ops) {
+		// Find the next change.
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// Hunks are merged below whenever their context would touch,
+		// so the leading context never overlaps the previous hunk.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != opEqual {
+				end++
+			}
+			// Extend through the following equal run if another change
+			// starts within 2*context lines; otherwise stop.
+			next := end
+			for next < len(ops) && ops[next].kind == opEqual {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			end += context
+			if end > len(ops) {
+				end = len(ops)
+			}
+			break
+		}
+
+		out = append(out, hunk{ops: ops[start:end]})
+		i = end
+	}
+	return out
+}
+
+// writeHunk writes a single "@@ -l,s +l,s @@" hunk.
+func writeHunk(buf *strings.Builder, h hunk, a, b []string) {
+	aStart, bStart := -1, -1
+	var aCount, bCount int
+	for _, op := range h.ops {
+		switch op.kind {
+		case opEqual:
+			if aStart < 0 {
+				aStart = op.a
+			}
+			if bStart < 0 {
+				bStart = op.b
+			}
+			aCount++
+			bCount++
+		case opDelete:
+			if aStart < 0 {
+				aStart = op.a
+			}
+			aCount++
+		case opInsert:
+			if bStart < 0 {
+				bStart = op.b
+			}
+			bCount++
+		}
+	}
+	// An empty side is anchored to the line before the hunk.
+	first := h.ops[0]
+	if aStart < 0 {
+		aStart = first.a
+	}
+	if bStart < 0 {
+		bStart = first.b
+	}
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, op := range h.ops {
+		switch op.kind {
+		case opEqual:
+			writeLine(buf, ' ', a[op.a])
+		case opDelete:
+			writeLine(buf, '-', a[op.a])
+		case opInsert:
+			writeLine(buf, '+', b[op.b])
+		}
+	}
+}
+
+// hunkRange formats a 0-based start and line count as a unified diff
+// range. Empty ranges refer to the line preceding the change.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writeLine writes one diff line, marking a missing final newline.
+func writeLine(buf *strings.Builder, prefix byte, line string) {
+	buf.WriteByte(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
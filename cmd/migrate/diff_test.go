@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		context int
+		want    string
+	}{
+		{
+			name: "identical input",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name:    "single changed line",
+			old:     "x\nimport a from './a.json' assert { type: 'json' };\ny\n",
+			new:     "x\nimport a from './a.json' with { type: 'json' };\ny\n",
+			context: 3,
+			want: strings.Join([]string{
+				"--- a/src/a.js",
+				"+++ b/src/a.js",
+				"@@ -1,3 +1,3 @@",
+				" x",
+				"-import a from './a.json' assert { type: 'json' };",
+				"+import a from './a.json' with { type: 'json' };",
+				" y",
+				"",
+			}, "\n"),
+		},
+		{
+			name:    "distant changes produce separate hunks",
+			old:     "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:     "one\n2\n3\n4\n5\n6\n7\neight\n",
+			context: 1,
+			want: strings.Join([]string{
+				"--- a/src/a.js",
+				"+++ b/src/a.js",
+				"@@ -1,2 +1,2 @@",
+				"-1",
+				"+one",
+				" 2",
+				"@@ -7,2 +7,2 @@",
+				" 7",
+				"-8",
+				"+eight",
+				"",
+			}, "\n"),
+		},
+		{
+			name:    "nearby changes are merged",
+			old:     "1\n2\n3\n4\n",
+			new:     "one\n2\n3\nfour\n",
+			context: 1,
+			want: strings.Join([]string{
+				"--- a/src/a.js",
+				"+++ b/src/a.js",
+				"@@ -1,4 +1,4 @@",
+				"-1",
+				"+one",
+				" 2",
+				" 3",
+				"-4",
+				"+four",
+				"",
+			}, "\n"),
+		},
+		{
+			name:    "pure insertion with zero context",
+			old:     "a\nc\n",
+			new:     "a\nb\nc\n",
+			context: 0,
+			want: strings.Join([]string{
+				"--- a/src/a.js",
+				"+++ b/src/a.js",
+				"@@ -1,0 +2 @@",
+				"+b",
+				"",
+			}, "\n"),
+		},
+		{
+			name:    "missing final newline",
+			old:     "a\nassert",
+			new:     "a\nwith",
+			context: 3,
+			want: strings.Join([]string{
+				"--- a/src/a.js",
+				"+++ b/src/a.js",
+				"@@ -1,2 +1,2 @@",
+				" a",
+				"-assert",
+				`\ No newline at end of file`,
+				"+with",
+				`\ No newline at end of file`,
+				"",
+			}, "\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("src/a.js", []byte(tt.old), []byte(tt.new), nil, tt.context)
+			if got != tt.want {
+				t.Errorf("diff mismatch:\n  got:\n%s\n  want:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff_Edits(t *testing.T) {
+	sources := []string{
+		"import a from './a.json' assert { type: 'json' };\n",
+		"x\nimport a from './a.json' assert { type: 'json' }; import b from './b.json' assert { type: 'json' };\ny\n",
+		"import a from './a.json' assert {\n  type: 'json'\n};\nconst b = 1;\nconst c = await import('./c.json', {\n  assert: { type: 'json' },\n});\n",
+		"1\n2\nimport a from './a.json' assert { type: 'json' };\n3\n4\n5\n6\n7\nimport b from './b.json' assert { type: 'json' }",
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	for i, src := range sources {
+		for _, strip := range []bool{false, true} {
+			var res *transform.Result
+			var err error
+			if strip {
+				res, err = mig.StripAttributes([]byte(src), transform.TypeScript)
+			} else {
+				res, err = mig.MigrateAssertToWith([]byte(src), transform.TypeScript)
+			}
+			if err != nil {
+				t.Fatalf("source %d: %v", i, err)
+			}
+			for _, context := range []int{0, 1, 3} {
+				want := unifiedDiff("src/a.ts", []byte(src), res.Output, nil, context)
+				got := unifiedDiff("src/a.ts", []byte(src), res.Output, res.Edits, context)
+				if got != want {
+					t.Errorf("source %d (strip %v, context %d): diff from edits differs:\n  got:\n%s\n  want:\n%s", i, strip, context, got, want)
+				}
+			}
+		}
+	}
+}
+
+// largeMigration returns a large module with many scattered import()
+// calls to migrate, and its migration.
+func largeMigration(tb testing.TB) ([]byte, *transform.Result) {
+	var buf strings.Builder
+	for i := 0; i < 1500; i++ {
+		fmt.Fprintf(&buf, "export async function load%d() {\n", i)
+		fmt.Fprintf(&buf, "\tconst data = await import('./data%d.json', { assert: { type: 'json' } });\n", i)
+		fmt.Fprintf(&buf, "\treturn data.default.items.map((item) => item.id + %d);\n}\n\n", i)
+	}
+	source := []byte(buf.String())
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+	res, err := mig.MigrateAssertToWith(source, transform.TypeScript)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return source, res
+}
+
+func TestUnifiedDiff_LargeFile(t *testing.T) {
+	source, res := largeMigration(t)
+	got := unifiedDiff("src/a.ts", source, res.Output, res.Edits, 0)
+	if n := strings.Count(got, "\n@@ "); n != 1500 || !strings.Contains(got, "+\tconst data = await import('./data1499.json', { with: { type: 'json' } });\n") {
+		t.Errorf("expected 1500 one-line hunks, got %d", n)
+	}
+}
+
+// BenchmarkUnifiedDiff_LargeFile diffs a large file with 1,500 migrated
+// lines, with and without the edits to narrow the search.
+func BenchmarkUnifiedDiff_LargeFile(b *testing.B) {
+	source, res := largeMigration(b)
+
+	b.Run("edits", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			unifiedDiff("src/a.ts", source, res.Output, res.Edits, 3)
+		}
+	})
+	b.Run("myers", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			unifiedDiff("src/a.ts", source, res.Output, nil, 3)
+		}
+	})
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// migrateIgnoreFile is the tool-specific ignore file. It uses .gitignore
+// syntax and is read after .gitignore in the same directory, so its
+// patterns (including negations) take precedence.
+const migrateIgnoreFile = ".migrateignore"
+
+// defaultIgnores are applied below every ignore file. Because they are
+// ordinary patterns, a .gitignore or .migrateignore can re-include one
+// of them with a negation such as `!build/`.
+var defaultIgnores = []string{
+	".*/",
+	"node_modules/",
+	"vendor/",
+	"dist/",
+	"build/",
+}
+
+// ignorePattern is a single compiled .gitignore-style pattern.
+type ignorePattern struct {
+	// dir is the absolute directory the pattern is relative to.
+	dir     string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// matches reports whether the pattern matches absPath, which must be an
+// absolute, cleaned path.
+func (p ignorePattern) matches(absPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(p.dir, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return p.re.MatchString(filepath.ToSlash(rel))
+}
+
+// ignoreList is an ordered set of patterns. As in git, the last pattern
+// that matches a path decides whether it is ignored.
+type ignoreList []ignorePattern
+
+// ignored reports whether absPath is excluded by the list.
+func (l ignoreList) ignored(absPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range l {
+		if p.matches(absPath, isDir) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// any reports whether any pattern in the list matches absPath, ignoring
+// negation. It is used for -include and -exclude globs.
+func (l ignoreList) any(absPath string, isDir bool) bool {
+	for _, p := range l {
+		if p.matches(absPath, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileIgnorePatterns compiles each line of .gitignore syntax relative
+// to dir, skipping blanks and comments.
+func compileIgnorePatterns(lines []string, dir string) ignoreList {
+	var list ignoreList
+	for _, line := range lines {
+		if p, ok := compileIgnorePattern(line, dir); ok {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// loadIgnoreFile reads a .gitignore-style file. A missing file yields
+// no patterns.
+func loadIgnoreFile(path, dir string) (ignoreList, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return compileIgnorePatterns(lines, dir), nil
+}
+
+// loadDirIgnores loads the ignore files that live directly in dir.
+func loadDirIgnores(dir string, gitignore bool) (ignoreList, error) {
+	names := []string{migrateIgnoreFile}
+	if gitignore {
+		names = []string{".gitignore", migrateIgnoreFile}
+	}
+
+	var list ignoreList
+	for _, name := range names {
+		patterns, err := loadIgnoreFile(filepath.Join(dir, name), dir)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, patterns...)
+	}
+	return list, nil
+}
+
+// loadAncestorIgnores loads ignore files from the enclosing git
+// repository's top level down to (but not including) absRoot, so that
+// running on a subdirectory honours the repository's ignore rules. If
+// absRoot is not inside a git repository, nothing is loaded.
+func loadAncestorIgnores(absRoot string, gitignore bool) (ignoreList, error) {
+	if isRepoTop(absRoot) {
+		return nil, nil
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(absRoot); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if isRepoTop(dir) {
+			break
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			// Reached the filesystem root without finding a repository.
+			return nil, nil
+		}
+	}
+
+	var list ignoreList
+	for i := len(dirs) - 1; i >= 0; i-- {
+		patterns, err := loadDirIgnores(dirs[i], gitignore)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, patterns...)
+	}
+	return list, nil
+}
+
+// isRepoTop reports whether dir is the top level of a git work tree.
+func isRepoTop(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// compileIgnorePattern converts one line of .gitignore syntax into a
+// pattern relative to dir. It returns false for blank lines and comments.
+func compileIgnorePattern(line, dir string) (ignorePattern, bool) {
+	line = trimTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	p := ignorePattern{dir: dir}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to dir; otherwise
+	// it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+
+	segs := strings.Split(line, "/")
+	for i, seg := range segs {
+		first, last := i == 0, i == len(segs)-1
+		switch {
+		case seg == "**" && first && last:
+			re.WriteString(".*")
+		case seg == "**" && first:
+			re.WriteString("(?:.*/)?")
+		case seg == "**" && last:
+			re.WriteString("/.*")
+		case seg == "**":
+			re.WriteString("(?:/.*)?")
+		default:
+			if !first && !(i == 1 && segs[0] == "**") {
+				re.WriteString("/")
+			}
+			re.WriteString(globToRegexp(seg))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = compiled
+	return p, true
+}
+
+// globToRegexp translates a single path segment of glob syntax (`*`,
+// `?`, `[...]` and backslash escapes) into a regular expression.
+func globToRegexp(seg string) string {
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		switch c {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '\\':
+			if i+1 < len(seg) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(seg[i])))
+			}
+		case '[':
+			end := strings.IndexByte(seg[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := seg[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// trimTrailingSpace removes trailing spaces unless they are escaped
+// with a backslash.
+func trimTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-2] + " "
+	}
+	return line
+}
+
+// globList is a repeatable flag holding comma-separated glob patterns.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*g = append(*g, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompileIgnorePattern(t *testing.T) {
+	const dir = "/repo"
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.gen.ts", "a.gen.ts", false, true},
+		{"*.gen.ts", "src/deep/a.gen.ts", false, true},
+		{"*.gen.ts", "src/a.ts", false, false},
+		{"build/", "build", true, true},
+		{"build/", "src/build", true, true},
+		{"build/", "build", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"src/*.js", "src/a.js", false, true},
+		{"src/*.js", "src/x/a.js", false, false},
+		{"src/*.js", "lib/src/a.js", false, false},
+		{"**/fixtures", "a/b/fixtures", true, true},
+		{"**/fixtures", "fixtures", true, true},
+		{"legacy/**", "legacy/a/b.js", false, true},
+		{"legacy/**", "legacy", true, false},
+		{"a/**/b.js", "a/b.js", false, true},
+		{"a/**/b.js", "a/x/y/b.js", false, true},
+		{"file?.js", "file1.js", false, true},
+		{"file?.js", "file10.js", false, false},
+		{"file[0-9].js", "file5.js", false, true},
+		{"file[!0-9].js", "file5.js", false, false},
+		{`\#hash.js`, "#hash.js", false, true},
+		{"trailing.js   ", "trailing.js", false, true},
+	}
+
+	for _, tt := range tests {
+		p, ok := compileIgnorePattern(tt.pattern, dir)
+		if !ok {
+			t.Errorf("compileIgnorePattern(%q) rejected the pattern", tt.pattern)
+			continue
+		}
+		if got := p.matches(filepath.Join(dir, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("pattern %q on %q (dir=%v): got %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := compileIgnorePattern(line, dir); ok {
+			t.Errorf("compileIgnorePattern(%q) should yield no pattern", line)
+		}
+	}
+}
+
+func TestIgnoreList_Negation(t *testing.T) {
+	list := compileIgnorePatterns([]string{"*.js", "!keep.js"}, "/repo")
+	if !list.ignored("/repo/drop.js", false) {
+		t.Error("drop.js should be ignored")
+	}
+	if list.ignored("/repo/keep.js", false) {
+		t.Error("keep.js should be re-included by the negation")
+	}
+}
+
+func TestCollectFiles_Ignores(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{
+		".gitignore",
+		"src/a.js",
+		"src/generated/g.js",
+		"src/nested/.gitignore",
+		"src/nested/skip.js",
+		"src/nested/keep.js",
+		"build/out.js",
+		"dist/out.js",
+		"node_modules/pkg/index.js",
+		".hidden/h.js",
+		"lib/.migrateignore",
+		"lib/legacy.js",
+		"lib/modern.ts",
+		"tools/build/script.js",
+	} {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "generated/\n")
+	write("src/nested/.gitignore", "*.js\n!keep.js\n")
+	write("lib/.migrateignore", "legacy.js\n")
+	// Re-include build directories below tools/ only.
+	write(".migrateignore", "!tools/build/\n")
+
+	extSet := parseExtensions(".js,.ts")
+	rel := func(files []string) []string {
+		var out []string
+		for _, f := range files {
+			r, err := filepath.Rel(root, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+
+	files, err := collectFiles(root, extSet, walkOptions{recursive: true, gitignore: true})
+	if err != nil {
+		t.Fatalf("collectFiles: %v", err)
+	}
+	want := []string{"lib/modern.ts", "src/a.js", "src/nested/keep.js", "tools/build/script.js"}
+	if got := rel(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("files:\n  got:  %v\n  want: %v", got, want)
+	}
+
+	files, err = collectFiles(root, extSet, walkOptions{recursive: true, gitignore: false})
+	if err != nil {
+		t.Fatalf("collectFiles: %v", err)
+	}
+	want = []string{"lib/modern.ts", "src/a.js", "src/generated/g.js", "src/nested/keep.js", "src/nested/skip.js", "tools/build/script.js"}
+	if got := rel(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("files without .gitignore:\n  got:  %v\n  want: %v", got, want)
+	}
+
+	files, err = collectFiles(root, extSet, walkOptions{
+		recursive: true,
+		gitignore: true,
+		include:   []string{"src/**"},
+		exclude:   []string{"keep.js"},
+	})
+	if err != nil {
+		t.Fatalf("collectFiles: %v", err)
+	}
+	want = []string{"src/a.js"}
+	if got := rel(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("files with -include/-exclude:\n  got:  %v\n  want: %v", got, want)
+	}
+}
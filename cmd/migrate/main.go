@@ -10,9 +10,44 @@
 //
 //	-w          Write changes back to files (default: print to stdout)
 //	-dry-run    Show which files would be changed without modifying them
-//	-ext        Comma-separated file extensions to process (default: .js,.jsx,.ts,.tsx,.mjs,.mts)
+//	-check      Report remaining import assertions and exit 3 if any are found (for CI)
+//	-reverse    Migrate import attributes (`with`) back to import assertions (`assert`)
+//	-target     Runtime the code must run on (e.g. node18.19, deno1.40, chrome123);
+//	            picks the direction and reports syntax the runtime cannot run
+//	-strip      Remove `assert { ... }` / `with { ... }` clauses entirely (bundler-only code)
+//	-add-attributes  Add `with { type: ... }` to bare imports of these extensions (e.g. .json,.css)
+//	-rules      JSON file of tree-sitter query rules to apply as well (repeatable)
+//	-diff       Print a unified diff of the changes instead of the rewritten files
+//	-diff-context  Number of context lines in -diff output (default: 3)
+//	-ext        Comma-separated file extensions to process (default: .js,.jsx,.ts,.tsx,.mjs,.mts,.cjs,.cts)
 //	-dump       Dump the S-expression tree for the first file and exit (debug)
 //	-recursive  Recurse into directories (default: true)
+//	-sniff      Also process extensionless scripts detected by their shebang,
+//	            `// @ts-` pragmas or ESM syntax
+//	-gitignore  Skip files matched by .gitignore files (default: true)
+//	-include    Only process files matching a glob (repeatable)
+//	-exclude    Skip files and directories matching a glob (repeatable)
+//	-j          Number of files to process in parallel (default: number of CPUs)
+//	-prefilter  Skip parsing files that cannot contain an import assertion (default: true)
+//	-verify     Re-parse migrated output and leave files whose output has new
+//	            syntax errors untouched (default: true)
+//	-strict     Fail on files with parse errors and leave them untouched
+//	-fallback   Retry with the other grammars (JavaScript, TSX, TypeScript) when
+//	            a file has parse errors near its imports (default: true)
+//	-min-confidence  Only apply edits of at least this confidence (low, medium or
+//	            high); report the rest for manual review (default: low)
+//	-format     Report format: text, json (one document), jsonl (one record per line) or sarif
+//
+// Exit codes:
+//
+//	0  success (in -check mode: no import assertions remain)
+//	1  usage error, a file could not be read, parsed, verified or written,
+//	   a file uses syntax that -target cannot run (outside -check), or
+//	   with -strict a file has parse errors
+//	3  -check mode found import assertions (or, with -reverse, import
+//	   attributes, or with -strip any clause) that need migrating, a
+//	   -rules rule that would edit a file, or syntax that -target
+//	   cannot run
 package main
 
 import (
@@ -21,19 +56,50 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/netlify/import-attr-migrator/transform"
 )
 
+// Exit codes. exitNeedsMigration is kept distinct from exitFailure so CI
+// can tell "assertions remain" apart from "the tool could not run".
+const (
+	exitOK             = 0
+	exitFailure        = 1
+	exitNeedsMigration = 3
+)
+
 func main() {
 	var (
 		write     = flag.Bool("w", false, "write result back to source files")
 		dryRun    = flag.Bool("dry-run", false, "show which files would change without modifying them")
-		exts      = flag.String("ext", ".js,.jsx,.ts,.tsx,.mjs,.mts", "comma-separated file extensions to process")
+		check     = flag.Bool("check", false, "report remaining import assertions and exit 3 if any are found")
+		diff      = flag.Bool("diff", false, "print a unified diff of the changes instead of the rewritten files")
+		diffCtx   = flag.Int("diff-context", 3, "number of context lines in -diff output")
+		jobs      = flag.Int("j", runtime.NumCPU(), "number of files to process in parallel")
+		prefilter = flag.Bool("prefilter", true, "skip parsing files that cannot contain an import assertion")
+		verify    = flag.Bool("verify", true, "re-parse migrated output and skip files whose output would have new syntax errors")
+		fallback  = flag.Bool("fallback", true, "retry with the other grammars when a file has parse errors near its imports")
+		strict    = flag.Bool("strict", false, "fail on files with parse errors: leave them untouched and exit 1")
+		minConf   = flag.String("min-confidence", "low", "only apply edits of at least this confidence `level` (low, medium or high) and report the rest for manual review")
+		reverse   = flag.Bool("reverse", false, "migrate import attributes (with) back to import assertions (assert)")
+		target    = flag.String("target", "", "runtime the code must run on (e.g. node18.19, node22, deno1.40, chrome123); picks the direction and reports unsupported syntax")
+		strip     = flag.Bool("strip", false, "remove assert/with clauses entirely (for code only consumed by a bundler)")
+		addAttrs  = flag.String("add-attributes", "", "comma-separated specifier `extensions` (e.g. .json,.css) whose imports without attributes get a type attribute added")
+		format    = flag.String("format", "text", "report format: text, json, jsonl or sarif")
+		exts      = flag.String("ext", ".js,.jsx,.ts,.tsx,.mjs,.mts,.cjs,.cts", "comma-separated file extensions to process")
 		dump      = flag.Bool("dump", false, "dump S-expression tree for the first file and exit")
 		recursive = flag.Bool("recursive", true, "recurse into directories")
+		sniff     = flag.Bool("sniff", false, "also process extensionless files whose content looks like a script (node shebang, // @ts- pragma or ESM syntax)")
+		gitignore = flag.Bool("gitignore", true, "skip files matched by .gitignore files")
+		include   globList
+		exclude   globList
+		ruleFiles globList
 	)
+	flag.Var(&include, "include", "only process files matching this glob (repeatable, comma-separated)")
+	flag.Var(&exclude, "exclude", "skip files and directories matching this glob (repeatable, comma-separated)")
+	flag.Var(&ruleFiles, "rules", "JSON `file` of tree-sitter query rules to apply along with the migration (repeatable, comma-separated)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <file|dir> [file|dir...]\n\n", os.Args[0])
@@ -43,7 +109,16 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s -w ./src                 # Rewrite all files in src/\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -dry-run ./src           # Preview which files would change\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -check ./src             # Fail (exit 3) if any assertions remain\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -diff ./src | git apply  # Review or apply changes as a patch\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -check -format json ./src # Machine-readable report\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -check -format sarif ./src > results.sarif  # Code-scanning alerts\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s ./src/foo.ts             # Print migrated file to stdout\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -reverse -w ./src        # Rewrite with back to assert for older runtimes\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -target node18.19 -w ./src  # Use whichever keyword Node.js 18.19 accepts\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -add-attributes .json -w ./src  # Also add with { type: 'json' } to bare JSON imports\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -strip -w ./src          # Remove assert/with clauses for bundler-only code\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -rules codemods.json -w ./src  # Also apply your own query rules\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -dump ./src/foo.ts       # Show parsed S-expression tree\n", os.Args[0])
 	}
 
@@ -51,7 +126,17 @@ func main() {
 
 	if flag.NArg() == 0 {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitFailure)
+	}
+
+	modes := 0
+	for _, set := range []bool{*write, *dryRun, *check, *diff} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fatalf("only one of -w, -dry-run, -check or -diff may be given")
 	}
 
 	extSet := parseExtensions(*exts)
@@ -63,7 +148,7 @@ func main() {
 		if err != nil {
 			fatalf("reading %s: %v", path, err)
 		}
-		lang := languageForFile(path)
+		lang := languageFor(path, source)
 		sexp, err := transform.DumpTree(source, lang)
 		if err != nil {
 			fatalf("parsing %s: %v", path, err)
@@ -73,6 +158,13 @@ func main() {
 	}
 
 	// Collect files to process.
+	walkOpts := walkOptions{
+		recursive: *recursive,
+		gitignore: *gitignore,
+		include:   include,
+		exclude:   exclude,
+		sniff:     *sniff,
+	}
 	var files []string
 	for _, arg := range flag.Args() {
 		info, err := os.Stat(arg)
@@ -81,7 +173,7 @@ func main() {
 		}
 
 		if info.IsDir() {
-			dirFiles, err := collectFiles(arg, extSet, *recursive)
+			dirFiles, err := collectFiles(arg, extSet, walkOpts)
 			if err != nil {
 				fatalf("walking %s: %v", arg, err)
 			}
@@ -91,96 +183,236 @@ func main() {
 		}
 	}
 
-	if len(files) == 0 {
+	if len(files) == 0 && *format == "text" {
 		fmt.Fprintf(os.Stderr, "no matching files found\n")
-		os.Exit(0)
+		os.Exit(exitOK)
 	}
 
-	// Process each file.
-	var (
-		totalFiles        int
-		totalReplacements int
-	)
+	m := modePrint
+	switch {
+	case *write:
+		m = modeWrite
+	case *dryRun:
+		m = modeDryRun
+	case *check:
+		m = modeCheck
+	case *diff:
+		m = modeDiff
+	}
 
-	for _, path := range files {
-		source, err := os.ReadFile(path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "WARN: skipping %s: %v\n", path, err)
-			continue
+	// The a/ and b/ headers of a diff are relative to where it is
+	// applied, so -diff only accepts files below the working directory.
+	if m == modeDiff {
+		for _, f := range files {
+			if _, err := workDirPath(f); err != nil {
+				fatalf("-diff: %v; run from a directory that contains it", err)
+			}
 		}
+	}
 
-		lang := languageForFile(path)
-		result, err := transform.MigrateAssertToWith(source, lang)
+	p := plan{dir: transform.AssertToWith, strip: *strip, strict: *strict}
+	if *strip && (*reverse || *target != "" || *addAttrs != "") {
+		fatalf("-strip cannot be combined with -reverse, -target or -add-attributes")
+	}
+	if *reverse {
+		p.dir = transform.WithToAssert
+	}
+	if *target != "" {
+		if *reverse {
+			fatalf("-reverse and -target cannot be combined; -target picks the direction")
+		}
+		t, err := transform.ParseTarget(*target)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "WARN: skipping %s: %v\n", path, err)
-			continue
+			fatalf("%v", err)
 		}
+		p.target = &t
+		p.dir, _ = t.Direction()
+	}
 
-		if result.Replacements == 0 {
-			continue
+	minConfidence, err := transform.ParseConfidence(*minConf)
+	if err != nil {
+		fatalf("-min-confidence: %v", err)
+	}
+
+	var types map[string]string
+	if *addAttrs != "" {
+		types = attributeTypes(*addAttrs)
+	}
+	if len(ruleFiles) > 0 {
+		custom, err := loadRules(ruleFiles)
+		if err != nil {
+			fatalf("loading rules: %v", err)
 		}
+		p.custom = custom
+	}
+	p.rules = planRules(p, types)
 
-		totalFiles++
-		totalReplacements += result.Replacements
+	rep, err := newReporter(*format, m, p, *diffCtx)
+	if err != nil {
+		fatalf("%v", err)
+	}
 
-		if *dryRun {
-			fmt.Printf("  %s (%d replacement(s))\n", path, result.Replacements)
-			continue
-		}
+	// Workers share one Migrator so parsers are reused across files.
+	opts := []transform.Option{
+		transform.WithPrefilter(*prefilter),
+		transform.WithVerify(*verify),
+		transform.WithGrammarFallback(*fallback),
+	}
+	if types != nil {
+		opts = append(opts, transform.WithAddAttributes(types))
+	}
+	// Check mode reports every edit, with its confidence.
+	if m != modeCheck {
+		opts = append(opts, transform.WithMinConfidence(minConfidence))
+	}
+	mig := transform.NewMigrator(opts...)
+	defer mig.Close()
 
-		if *write {
-			// Preserve original file permissions.
-			info, err := os.Stat(path)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "WARN: stat %s: %v\n", path, err)
-				continue
-			}
+	// Process files in parallel, reporting in input order.
+	var sum summary
+	work := func(i int) fileResult { return processFile(mig, files[i], m, p) }
+	forEachOrdered(len(files), *jobs, work, func(r fileResult) {
+		sum.add(r)
+		rep.file(r)
+	})
 
-			if err := os.WriteFile(path, result.Output, info.Mode()); err != nil {
-				fmt.Fprintf(os.Stderr, "ERROR: writing %s: %v\n", path, err)
-				continue
-			}
+	stats := mig.Stats()
+	sum.Parsed = stats.Parsed
+	sum.Prefiltered = stats.Prefiltered
+	rep.summary(sum)
 
-			fmt.Printf("  ✓ %s (%d replacement(s))\n", path, result.Replacements)
-		} else {
-			// No -w flag: print to stdout (only useful for single files).
-			os.Stdout.Write(result.Output)
-		}
+	if code := exitCode(sum, m, *strict); code != exitOK {
+		os.Exit(code)
 	}
+}
 
-	if *dryRun || *write {
-		fmt.Fprintf(os.Stderr, "\n%d file(s) with %d total replacement(s)\n", totalFiles, totalReplacements)
+// exitCode returns the process exit code for a run in mode m that
+// produced sum.
+func exitCode(sum summary, m mode, strict bool) int {
+	// A file that could not be read, parsed, verified or written was
+	// left as it was. In -check mode an incomplete scan cannot vouch for
+	// the tree either, so failures win over findings.
+	if sum.Failures > 0 {
+		return exitFailure
+	}
+	if m == modeCheck && (sum.ChangedFiles > 0 || sum.Unsupported > 0 || sum.Unmigratable > 0) {
+		return exitNeedsMigration
+	}
+	// Outside -check nothing was rewritten for syntax the target cannot
+	// run, so the run did not make the tree runnable.
+	if sum.Unsupported > 0 {
+		return exitFailure
 	}
+	if strict && sum.ParseErrors > 0 {
+		return exitFailure
+	}
+	return exitOK
 }
 
-// collectFiles walks a directory and returns all files matching the extension set.
-func collectFiles(root string, extSet map[string]bool, recursive bool) ([]string, error) {
-	var files []string
+// planRules returns the rules to run over every file for -strip or
+// -rules, or nil when the plan is a plain migration. types is as for
+// transform.WithAddAttributes.
+func planRules(p plan, types map[string]string) []transform.Rule {
+	if !p.strip && p.custom == nil {
+		return nil
+	}
 
+	var rules []transform.Rule
+	switch {
+	case p.strip:
+		rules = append(rules, transform.StripRule())
+	default:
+		rules = append(rules, transform.MigrateRule(p.dir))
+		if types != nil {
+			rules = append(rules, transform.AddAttributesRule(p.dir, types))
+		}
+	}
+	for _, c := range p.custom {
+		rules = append(rules, c.rule)
+	}
+	return rules
+}
+
+// walkOptions controls which files collectFiles returns.
+type walkOptions struct {
+	recursive bool
+	// gitignore enables .gitignore handling; .migrateignore files and
+	// the default ignores always apply.
+	gitignore bool
+	// include, if non-empty, restricts results to files matching one of
+	// the globs. exclude drops matching files and directories.
+	include []string
+	exclude []string
+	// sniff also returns extensionless files whose content looks like a
+	// script; see sniffScript.
+	sniff bool
+}
+
+// collectFiles walks a directory and returns all files matching the
+// extension set (or, with sniff, extensionless scripts) that are not
+// excluded by the default ignores, by .gitignore/.migrateignore files,
+// or by the -include/-exclude globs.
+func collectFiles(root string, extSet map[string]bool, opts walkOptions) ([]string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ignores := compileIgnorePatterns(defaultIgnores, absRoot)
+	ancestors, err := loadAncestorIgnores(absRoot, opts.gitignore)
+	if err != nil {
+		return nil, err
+	}
+	ignores = append(ignores, ancestors...)
+
+	include := compileIgnorePatterns(opts.include, absRoot)
+	exclude := compileIgnorePatterns(opts.exclude, absRoot)
+
+	var files []string
 	walkFn := func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		abs := filepath.Join(absRoot, rel)
+
 		if d.IsDir() {
-			// Skip hidden directories and node_modules.
-			name := d.Name()
-			if name != "." && strings.HasPrefix(name, ".") {
-				return fs.SkipDir
-			}
-			if name == "node_modules" || name == "vendor" || name == "dist" || name == "build" {
-				return fs.SkipDir
+			// The root itself was requested explicitly, so it is never
+			// ignored.
+			if path != root {
+				if !opts.recursive || ignores.ignored(abs, true) || exclude.any(abs, true) {
+					return fs.SkipDir
+				}
 			}
-			if !recursive && path != root {
-				return fs.SkipDir
+
+			// Ignore files apply to everything below their directory.
+			dirIgnores, err := loadDirIgnores(abs, opts.gitignore)
+			if err != nil {
+				return err
 			}
+			ignores = append(ignores, dirIgnores...)
 			return nil
 		}
 
-		ext := filepath.Ext(path)
-		if extSet[ext] {
-			files = append(files, path)
+		wanted := hasExtension(path, extSet)
+		if !wanted && !(opts.sniff && filepath.Ext(path) == "") {
+			return nil
 		}
+		if ignores.ignored(abs, false) || exclude.any(abs, false) {
+			return nil
+		}
+		if len(include) > 0 && !include.any(abs, false) {
+			return nil
+		}
+		// Only read the file once it is known to be wanted.
+		if !wanted && !isScript(path) {
+			return nil
+		}
+		files = append(files, path)
 		return nil
 	}
 
@@ -190,21 +422,66 @@ func collectFiles(root string, extSet map[string]bool, recursive bool) ([]string
 	return files, nil
 }
 
+// diffPath returns path relative to the working directory, in the
+// slash-separated, "./"-free form that `git apply` expects after its a/
+// and b/ prefixes are stripped. A path outside the working directory is
+// only cleaned; -diff refuses such paths (see workDirPath).
+func diffPath(path string) string {
+	if rel, err := workDirPath(path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// workDirPath returns path relative to the working directory, or an
+// error if it lies outside it.
+func workDirPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the working directory", path)
+	}
+	return rel, nil
+}
+
 // languageForFile determines the tree-sitter Language based on file extension.
+//
+// Declaration files (.d.ts, .d.mts, .d.cts) end in a TypeScript
+// extension, so their import types are parsed as TypeScript too.
 func languageForFile(path string) transform.Language {
 	ext := filepath.Ext(path)
 	switch ext {
-	case ".ts", ".mts":
+	case ".ts", ".mts", ".cts":
 		return transform.TypeScript
 	case ".tsx":
 		return transform.TSX
 	default:
-		// .js, .jsx, .mjs — use JavaScript grammar.
+		// .js, .jsx, .mjs, .cjs — use JavaScript grammar.
 		// JSX is a superset handled by the JS grammar.
 		return transform.JavaScript
 	}
 }
 
+// attributeTypes maps each extension in a comma-separated list to the
+// attribute type named after it, e.g. ".css" to "css".
+func attributeTypes(s string) map[string]string {
+	types := make(map[string]string)
+	for ext := range parseExtensions(s) {
+		types[ext] = strings.TrimPrefix(ext, ".")
+	}
+	return types
+}
+
 // parseExtensions splits a comma-separated extension list into a set.
 func parseExtensions(s string) map[string]bool {
 	m := make(map[string]bool)
@@ -221,7 +498,20 @@ func parseExtensions(s string) map[string]bool {
 	return m
 }
 
+// hasExtension reports whether the name of path ends in one of the
+// extensions in extSet. Extensions are matched as suffixes, so a
+// multi-part extension such as .d.ts selects only declaration files.
+func hasExtension(path string, extSet map[string]bool) bool {
+	name := filepath.Base(path)
+	for ext := range extSet {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
 func fatalf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
-	os.Exit(1)
+	os.Exit(exitFailure)
 }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		sum    summary
+		mode   mode
+		strict bool
+		want   int
+	}{
+		{"check clean", summary{Files: 3}, modeCheck, false, exitOK},
+		{"check needs migration", summary{Files: 3, ChangedFiles: 1, Replacements: 2}, modeCheck, false, exitNeedsMigration},
+		{"check unsupported", summary{Files: 3, Unsupported: 1}, modeCheck, false, exitNeedsMigration},
+		{"check computed keys", summary{Files: 3, Unmigratable: 1}, modeCheck, false, exitNeedsMigration},
+		{"check failure wins", summary{Files: 3, ChangedFiles: 1, Failures: 1}, modeCheck, false, exitFailure},
+		{"check parse errors", summary{Files: 3, ParseErrors: 1}, modeCheck, false, exitOK},
+		{"check strict parse errors", summary{Files: 3, ChangedFiles: 1, ParseErrors: 1, Failures: 1}, modeCheck, true, exitFailure},
+		{"write with changes", summary{Files: 3, ChangedFiles: 1, Replacements: 1}, modeWrite, false, exitOK},
+		{"write computed keys", summary{Files: 3, Unmigratable: 1}, modeWrite, false, exitOK},
+		{"write with failures", summary{Files: 3, Failures: 1}, modeWrite, false, exitFailure},
+		{"write unsupported", summary{Files: 3, Unsupported: 1}, modeWrite, false, exitFailure},
+		{"diff unsupported", summary{Files: 3, Unsupported: 1}, modeDiff, false, exitFailure},
+		{"dry run with failures", summary{Files: 3, ChangedFiles: 1, Failures: 1}, modeDryRun, false, exitFailure},
+		{"write strict parse errors", summary{Files: 3, ParseErrors: 1}, modeWrite, true, exitFailure},
+		{"dry run strict clean", summary{Files: 3, ChangedFiles: 1}, modeDryRun, true, exitOK},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.sum, tt.mode, tt.strict); got != tt.want {
+			t.Errorf("%s: exitCode = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExitCode_WriteError(t *testing.T) {
+	var sum summary
+	sum.add(fileResult{
+		path:     "a.js",
+		result:   &transform.Result{Replacements: 2},
+		writeErr: errors.New("read-only file system"),
+	})
+	if sum.Failures != 1 || sum.ChangedFiles != 0 || sum.Replacements != 0 {
+		t.Errorf("summary: got %+v, want 1 failure and no changes", sum)
+	}
+	if got := exitCode(sum, modeWrite, false); got != exitFailure {
+		t.Errorf("exitCode = %d, want %d", got, exitFailure)
+	}
+}
+
+func TestExitCode_ProcessedFile(t *testing.T) {
+	// Two assertions on one line are both counted.
+	src := filepath.Join(t.TempDir(), "a.js")
+	source := "import a from './a.json' assert { type: 'json' }; const b = await import('./b.json', { assert: { type: 'json' } });\n"
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	var sum summary
+	sum.add(processFile(mig, src, modeCheck, plan{dir: transform.AssertToWith}))
+	if sum.Replacements != 2 || sum.ChangedFiles != 1 {
+		t.Errorf("summary: got %+v, want 2 assertions in 1 file", sum)
+	}
+	if got := exitCode(sum, modeCheck, false); got != exitNeedsMigration {
+		t.Errorf("exitCode = %d, want %d", got, exitNeedsMigration)
+	}
+
+	sum.add(processFile(mig, filepath.Join(filepath.Dir(src), "missing.js"), modeCheck, plan{dir: transform.AssertToWith}))
+	if got := exitCode(sum, modeCheck, false); got != exitFailure {
+		t.Errorf("exitCode with an unreadable file = %d, want %d", got, exitFailure)
+	}
+}
+
+func TestExitCode_ComputedKey(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.js")
+	source := "import { KEY } from './keys.js';\nconst a = await import('./a.json', { [KEY]: { type: 'json' } });\n"
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	var sum summary
+	sum.add(processFile(mig, src, modeCheck, plan{dir: transform.AssertToWith}))
+	if sum.Unmigratable != 1 || sum.ChangedFiles != 0 {
+		t.Errorf("summary: got %+v, want 1 computed key and no changes", sum)
+	}
+	if got := exitCode(sum, modeCheck, false); got != exitNeedsMigration {
+		t.Errorf("exitCode = %d, want %d", got, exitNeedsMigration)
+	}
+}
+
+func TestDiffPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"src/a.js", "src/a.js"},
+		{"./src//a.js", "src/a.js"},
+		{filepath.Join(wd, "src", "a.js"), "src/a.js"},
+		{"../other/a.js", "../other/a.js"},
+	}
+	for _, tt := range tests {
+		if got := diffPath(tt.path); got != tt.want {
+			t.Errorf("diffPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := workDirPath(filepath.Join(wd, "src", "a.js")); err != nil {
+		t.Errorf("workDirPath: unexpected error for a path inside the working directory: %v", err)
+	}
+	for _, p := range []string{"../other/a.js", filepath.Dir(wd)} {
+		if _, err := workDirPath(p); err == nil {
+			t.Errorf("workDirPath(%q): expected an error for a path outside the working directory", p)
+		}
+	}
+}
+
+func TestLanguageForFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want transform.Language
+	}{
+		{"src/a.js", transform.JavaScript},
+		{"src/a.jsx", transform.JavaScript},
+		{"src/a.mjs", transform.JavaScript},
+		{"src/a.cjs", transform.JavaScript},
+		{"src/a.ts", transform.TypeScript},
+		{"src/a.mts", transform.TypeScript},
+		{"src/a.cts", transform.TypeScript},
+		{"src/a.tsx", transform.TSX},
+		{"types/a.d.ts", transform.TypeScript},
+		{"types/a.d.mts", transform.TypeScript},
+		{"types/a.d.cts", transform.TypeScript},
+	}
+
+	for _, tt := range tests {
+		if got := languageForFile(tt.path); got != tt.want {
+			t.Errorf("languageForFile(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasExtension(t *testing.T) {
+	tests := []struct {
+		exts string
+		path string
+		want bool
+	}{
+		{".js,.ts", "src/a.ts", true},
+		{".js,.ts", "types/a.d.ts", true},
+		{".js,.ts", "src/a.cts", false},
+		{".d.ts", "types/a.d.ts", true},
+		{".d.ts", "src/a.ts", false},
+		{".d.ts", "src/a.d.ts/b.js", false},
+		{"cjs", "src/a.cjs", true},
+	}
+
+	for _, tt := range tests {
+		if got := hasExtension(tt.path, parseExtensions(tt.exts)); got != tt.want {
+			t.Errorf("hasExtension(%q, %q) = %v, want %v", tt.path, tt.exts, got, tt.want)
+		}
+	}
+}
+
+// TestProcessFile_Extensions checks that each default extension is
+// collected and parsed with a grammar that finds its assertions.
+func TestProcessFile_Extensions(t *testing.T) {
+	files := map[string]string{
+		"a.mjs":   "import a from './a.json' assert { type: 'json' };\n",
+		"b.cjs":   "const b = await import('./b.json', { assert: { type: 'json' } });\nmodule.exports = b;\n",
+		"c.cts":   "import fs = require('fs');\nexport const c = import('./c.json', { assert: { type: 'json' } });\n",
+		"d.d.ts":  "export type D = typeof import('./d.json', { assert: { type: 'json' } });\n",
+		"e.d.mts": "import e from './e.json' assert { type: 'json' };\nexport declare const f: typeof e;\n",
+		"f.d.cts": "export type F = typeof import('./f.json', { assert: { type: 'json' } });\n",
+	}
+	root := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := collectFiles(root, parseExtensions(".js,.jsx,.ts,.tsx,.mjs,.mts,.cjs,.cts"), walkOptions{recursive: true})
+	if err != nil {
+		t.Fatalf("collectFiles: %v", err)
+	}
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	want := []string{"a.mjs", "b.cjs", "c.cts", "d.d.ts", "e.d.mts", "f.d.cts"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("collectFiles: got %v, want %v", names, want)
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	for _, p := range paths {
+		r := processFile(mig, p, modeDryRun, plan{dir: transform.AssertToWith})
+		if r.err != nil {
+			t.Errorf("%s: processFile: %v", filepath.Base(p), r.err)
+			continue
+		}
+		if r.count() != 1 || len(r.parseErrors) != 0 {
+			t.Errorf("%s: got %d edit(s) and parse errors %+v, want 1 edit and none",
+				filepath.Base(p), r.count(), r.parseErrors)
+		}
+	}
+}
+
+// TestProcessFile_ImportTypeReverse checks that -reverse and an
+// assert-only -target leave import types in declaration files on `with`.
+func TestProcessFile_ImportTypeReverse(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "t.d.ts")
+	source := `export type A = import("pkg", { with: { "resolution-mode": "require" } }).A;
+export declare const b: typeof import("./b.json", { with: { type: "json" } });
+import c from "./c.json" with { type: "json" };
+`
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	node18, err := transform.ParseTarget("node18.19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := node18.Direction()
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	for name, p := range map[string]plan{
+		"reverse": {dir: transform.WithToAssert},
+		"target":  {dir: dir, target: &node18},
+	} {
+		for _, m := range []mode{modeDryRun, modeCheck} {
+			r := processFile(mig, src, m, p)
+			if r.err != nil {
+				t.Fatalf("%s: processFile: %v", name, r.err)
+			}
+			if r.count() != 1 || len(r.unsupported) != 0 {
+				t.Errorf("%s (mode %d): got %d edit(s) and unsupported %+v, want only the static import", name, m, r.count(), r.unsupported)
+			}
+			if m == modeDryRun && strings.Count(string(r.result.Output), "{ with:") != 2 {
+				t.Errorf("%s: import types were rewritten:\n%s", name, r.result.Output)
+			}
+		}
+	}
+}
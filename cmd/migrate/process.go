@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// mode selects what the tool does with each migrated file.
+type mode int
+
+const (
+	// modePrint writes the migrated source to stdout.
+	modePrint mode = iota
+	// modeWrite rewrites files in place.
+	modeWrite
+	// modeDryRun lists the files that would change.
+	modeDryRun
+	// modeCheck lists remaining assertions without migrating them.
+	modeCheck
+	// modeDiff prints a unified diff for each changed file.
+	modeDiff
+)
+
+// fileResult is the outcome of processing a single file.
+type fileResult struct {
+	path string
+	// lang is the grammar the file was parsed with, which differs from
+	// the one for its extension after a fallback.
+	lang   transform.Language
+	source []byte
+	// result is the migration result; nil in modeCheck unless the plan
+	// has rules.
+	result *transform.Result
+	// findings holds the located assertions in modeCheck.
+	findings []transform.Finding
+	// unsupported holds the keywords that cannot run on the -target
+	// runtime when no migration can fix them.
+	unsupported []transform.Finding
+	// unmigratable holds the computed import() option keys, which may
+	// hide an assertion the migration cannot see.
+	unmigratable []transform.Finding
+	// parseErrors lists where the grammar could not parse the file,
+	// leaving out errors in attribute clauses the matcher found.
+	parseErrors []transform.ParseError
+	// err is set when the file could not be read or parsed and was skipped.
+	err error
+	// writeErr is set when the migrated file could not be written back.
+	writeErr error
+}
+
+// count returns the number of assertions found or replaced in the file.
+func (r fileResult) count() int {
+	if r.result != nil {
+		return r.result.Replacements
+	}
+	return len(r.findings)
+}
+
+// plan is the migration applied to every file.
+type plan struct {
+	dir transform.Direction
+	// target is the -target runtime, or nil if none was given.
+	target *transform.Target
+	// strip removes attribute clauses instead of migrating them.
+	strip bool
+	// rules, if non-nil, are run over every file with Migrator.Run in
+	// place of Migrate and Find. It is set for -strip and -rules.
+	rules []transform.Rule
+	// custom holds the rules loaded from -rules files.
+	custom []loadedRule
+	// strict fails files with parse errors instead of migrating them.
+	strict bool
+}
+
+// customRule returns the -rules rule with the given name.
+func (p plan) customRule(name string) (loadedRule, bool) {
+	for _, c := range p.custom {
+		if c.rule.Name() == name {
+			return c, true
+		}
+	}
+	return loadedRule{}, false
+}
+
+// unsafe reports whether the target accepts neither keyword, so files
+// are scanned for unsupported syntax instead of being migrated.
+func (p plan) unsafe() bool {
+	if p.target == nil {
+		return false
+	}
+	_, ok := p.target.Direction()
+	return !ok
+}
+
+// processFile reads, migrates and (in modeWrite) rewrites a single file.
+// It is safe to call concurrently for different paths.
+func processFile(mig *transform.Migrator, path string, m mode, p plan) fileResult {
+	r := fileResult{path: path, lang: languageForFile(path)}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.source = source
+	r.lang = languageFor(path, source)
+
+	lang := r.lang
+
+	if p.unsafe() {
+		r.unsupported, r.err = mig.FindUnsupported(source, lang, *p.target)
+		return r
+	}
+
+	// Rules have no Find counterpart, so every mode computes their
+	// edits; check mode just doesn't write them.
+	if p.rules != nil {
+		r.result, r.err = mig.Run(source, lang, p.rules...)
+		return r.checkSyntax(p).write(m)
+	}
+
+	// Check mode only needs locations, so skip building the output.
+	if m == modeCheck {
+		var scan *transform.ScanResult
+		if scan, r.err = mig.Scan(source, lang, p.dir); r.err == nil {
+			r.findings, r.parseErrors, r.lang = scan.Findings, scan.ParseErrors, scan.Language
+			r.unmigratable = scan.Unmigratable
+		}
+		return r.checkSyntax(p)
+	}
+
+	r.result, r.err = mig.Migrate(source, lang, p.dir)
+	return r.checkSyntax(p).write(m)
+}
+
+// checkSyntax records the parse errors of a migration and, with
+// -strict, fails a file that has any, so it is left untouched. Errors
+// in attribute clauses the matcher found are expected and not counted.
+func (r fileResult) checkSyntax(p plan) fileResult {
+	all := r.parseErrors
+	if r.result != nil {
+		all, r.lang = r.result.ParseErrors, r.result.Language
+		r.unmigratable = r.result.Unmigratable
+	}
+	r.parseErrors = nil
+	for _, e := range all {
+		if !e.Attribute {
+			r.parseErrors = append(r.parseErrors, e)
+		}
+	}
+	if p.strict && len(r.parseErrors) > 0 {
+		first := r.parseErrors[0].StartPoint
+		r.err = fmt.Errorf("%d parse error(s), the first at %d:%d (-strict)", len(r.parseErrors), first.Row+1, first.Column+1)
+		r.result, r.findings, r.unmigratable = nil, nil, nil
+	}
+	return r
+}
+
+// write rewrites the file with the migrated output in modeWrite.
+func (r fileResult) write(m mode) fileResult {
+	if r.err != nil || r.result.Replacements == 0 || m != modeWrite {
+		return r
+	}
+
+	// Preserve original file permissions.
+	info, err := os.Stat(r.path)
+	if err != nil {
+		r.writeErr = err
+		return r
+	}
+	r.writeErr = os.WriteFile(r.path, r.result.Output, info.Mode())
+	return r
+}
+
+// forEachOrdered runs work for indices 0..n-1 on up to jobs goroutines
+// and passes each result to emit in index order, so output stays
+// deterministic regardless of scheduling. At most a small multiple of
+// jobs results are buffered ahead of the one being emitted, which keeps
+// memory bounded on very large trees.
+func forEachOrdered(n, jobs int, work func(i int) fileResult, emit func(fileResult)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]chan fileResult, n)
+	for i := range results {
+		results[i] = make(chan fileResult, 1)
+	}
+
+	// window limits how far workers may run ahead of emit.
+	window := make(chan struct{}, 4*jobs)
+	indices := make(chan int)
+	go func() {
+		defer close(indices)
+		for i := 0; i < n; i++ {
+			window <- struct{}{}
+			indices <- i
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] <- work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		emit(<-results[i])
+		<-window
+	}
+	wg.Wait()
+}
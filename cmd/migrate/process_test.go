@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestForEachOrdered(t *testing.T) {
+	const n = 50
+	for _, jobs := range []int{0, 1, 4, 16} {
+		t.Run(fmt.Sprintf("jobs=%d", jobs), func(t *testing.T) {
+			work := func(i int) fileResult {
+				// Finish later indices first to exercise reordering.
+				time.Sleep(time.Duration(n-i) * 10 * time.Microsecond)
+				return fileResult{path: fmt.Sprint(i)}
+			}
+
+			var got []string
+			forEachOrdered(n, jobs, work, func(r fileResult) {
+				got = append(got, r.path)
+			})
+
+			if len(got) != n {
+				t.Fatalf("emitted %d results, want %d", len(got), n)
+			}
+			for i, p := range got {
+				if p != fmt.Sprint(i) {
+					t.Fatalf("result %d has path %q, want %q", i, p, fmt.Sprint(i))
+				}
+			}
+		})
+	}
+}
+
+func TestProcessFile_ParseErrors(t *testing.T) {
+	// The call on the last line is cut off.
+	source := "import a from './a.json' assert { type: 'json' };\nfoo(\n"
+	src := filepath.Join(t.TempDir(), "a.ts")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	for _, m := range []mode{modeCheck, modeDryRun} {
+		r := processFile(mig, src, m, plan{dir: transform.AssertToWith})
+		if r.err != nil {
+			t.Fatalf("processFile: %v", r.err)
+		}
+		if len(r.parseErrors) != 1 || r.parseErrors[0].StartPoint.Row != 1 {
+			t.Errorf("parse errors: got %+v, want one on line 2", r.parseErrors)
+		}
+		if r.count() != 1 {
+			t.Errorf("count: got %d, want 1", r.count())
+		}
+	}
+
+	r := processFile(mig, src, modeWrite, plan{dir: transform.AssertToWith, strict: true})
+	if r.err == nil {
+		t.Fatal("-strict should fail a file with parse errors")
+	}
+	var sum summary
+	sum.add(r)
+	if sum.Failures != 1 || sum.ParseErrors != 1 {
+		t.Errorf("summary: got %+v, want 1 failure with parse errors", sum)
+	}
+	if data, _ := os.ReadFile(src); string(data) != source {
+		t.Error("-strict must leave the file untouched")
+	}
+}
+
+func TestProcessFile_GrammarFallback(t *testing.T) {
+	// `import type` is TypeScript (or Flow) syntax the JavaScript
+	// grammar cannot parse.
+	source := "import type { T } from './t';\nimport a from './a.json' assert { type: 'json' };\n"
+	src := filepath.Join(t.TempDir(), "a.js")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fallback := range []bool{false, true} {
+		mig := transform.NewMigrator(transform.WithGrammarFallback(fallback))
+		for _, m := range []mode{modeCheck, modeDryRun} {
+			r := processFile(mig, src, m, plan{dir: transform.AssertToWith})
+			if r.err != nil {
+				t.Fatalf("processFile: %v", r.err)
+			}
+			want, errs := transform.JavaScript, 1
+			if fallback {
+				want, errs = transform.TSX, 0
+			}
+			if r.lang != want || len(r.parseErrors) != errs {
+				t.Errorf("fallback=%v mode=%d: parsed as %s with %d parse error(s), want %s with %d",
+					fallback, m, r.lang, len(r.parseErrors), want, errs)
+			}
+		}
+		mig.Close()
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// summary aggregates the outcome of a run.
+type summary struct {
+	// Files is the number of files processed, including failures.
+	Files int `json:"files"`
+	// ChangedFiles is the number of files with at least one replacement
+	// (or, in check mode, at least one remaining assertion).
+	ChangedFiles int `json:"changedFiles"`
+	Replacements int `json:"replacements"`
+	Failures     int `json:"failures"`
+	// Unsupported is the number of files using syntax that the -target
+	// runtime cannot run and no migration can fix.
+	Unsupported int `json:"unsupported"`
+	// Held is the number of edits below -min-confidence, which were
+	// left for manual review.
+	Held int `json:"held"`
+	// Unmigratable is the number of computed import() option keys,
+	// which were left for manual review.
+	Unmigratable int `json:"unmigratable"`
+	// ParseErrors is the number of files the grammar could not fully
+	// parse, including those -strict failed.
+	ParseErrors int   `json:"parseErrors"`
+	Parsed      int64 `json:"parsed"`
+	Prefiltered int64 `json:"prefiltered"`
+}
+
+// add folds a single file's result into the summary.
+func (s *summary) add(r fileResult) {
+	s.Files++
+	if len(r.parseErrors) > 0 {
+		s.ParseErrors++
+	}
+	if r.err != nil || r.writeErr != nil {
+		s.Failures++
+		return
+	}
+	if len(r.unsupported) > 0 {
+		s.Unsupported++
+	}
+	if r.result != nil {
+		s.Held += len(r.result.Held)
+	}
+	s.Unmigratable += len(r.unmigratable)
+	if n := r.count(); n > 0 {
+		s.ChangedFiles++
+		s.Replacements += n
+	}
+}
+
+// reporter renders per-file results and the final summary. Calls to
+// file arrive in input order from a single goroutine.
+type reporter interface {
+	file(r fileResult)
+	summary(s summary)
+}
+
+// newReporter returns the reporter for the -format flag value.
+func newReporter(format string, m mode, p plan, diffContext int) (reporter, error) {
+	switch format {
+	case "text":
+		return &textReporter{mode: m, plan: p, diffContext: diffContext}, nil
+	case "json":
+		return &jsonReporter{w: os.Stdout, mode: m, plan: p, diffContext: diffContext}, nil
+	case "jsonl":
+		return &jsonReporter{w: os.Stdout, mode: m, plan: p, diffContext: diffContext, lines: true}, nil
+	case "sarif":
+		return &sarifReporter{w: os.Stdout, plan: p}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (want text, json, jsonl or sarif)", format)
+	}
+}
+
+// textReporter produces the human-oriented output: warnings on stderr,
+// per-file lines (or rewritten sources, or diffs) on stdout, and totals
+// on stderr.
+type textReporter struct {
+	mode        mode
+	plan        plan
+	diffContext int
+	// parseErrors holds the files with parse errors, listed together in
+	// the summary.
+	parseErrors []fileResult
+}
+
+func (t *textReporter) file(r fileResult) {
+	if len(r.parseErrors) > 0 {
+		t.parseErrors = append(t.parseErrors, fileResult{path: r.path, parseErrors: r.parseErrors})
+	}
+	if r.err != nil {
+		fmt.Fprintf(os.Stderr, "WARN: skipping %s: %v\n", r.path, r.err)
+		return
+	}
+
+	if first := languageFor(r.path, r.source); r.lang != first {
+		fmt.Fprintf(os.Stderr, "NOTE: parsed %s as %s; the %s grammar failed near its imports\n", r.path, r.lang, first)
+	}
+
+	for _, f := range r.unsupported {
+		fmt.Fprintf(os.Stderr, "ERROR: %s:%d:%d: %s uses `%s`, which %s cannot run\n",
+			r.path, f.StartPoint.Row+1, f.StartPoint.Column+1, f.Kind, f.Text, t.plan.target)
+	}
+
+	if r.result != nil {
+		for _, e := range r.result.Held {
+			fmt.Fprintf(os.Stderr, "REVIEW: %s:%d:%d: %s %s (%s confidence, not applied)\n",
+				r.path, e.StartPoint.Row+1, e.StartPoint.Column+1, e.Kind, editMessage(e), e.Confidence)
+		}
+	}
+	for _, f := range r.unmigratable {
+		fmt.Fprintf(os.Stderr, "REVIEW: %s:%d:%d: %s %s\n",
+			r.path, f.StartPoint.Row+1, f.StartPoint.Column+1, f.Kind, computedKeyMessage(f, t.plan))
+	}
+
+	n := r.count()
+	if n == 0 {
+		return
+	}
+
+	switch t.mode {
+	case modeCheck:
+		if r.result != nil {
+			for _, e := range r.result.Edits {
+				fmt.Printf("%s:%d:%d: %s %s\n",
+					r.path, e.StartPoint.Row+1, e.StartPoint.Column+1, e.Kind, editMessage(e))
+			}
+		}
+		for _, f := range r.findings {
+			if f.Strategy == transform.MissingAttributes {
+				fmt.Printf("%s:%d:%d: %s has no import attributes; add `%s`\n",
+					r.path, f.StartPoint.Row+1, f.StartPoint.Column+1, f.Kind, strings.TrimLeft(f.Insertion, ", "))
+				continue
+			}
+			fmt.Printf("%s:%d:%d: %s uses `%s`; migrate to `%s` (%s, %s confidence)\n",
+				r.path, f.StartPoint.Row+1, f.StartPoint.Column+1, f.Kind, f.Text, t.plan.dir.To(), f.Strategy, f.Strategy.Confidence())
+		}
+	case modeDiff:
+		fmt.Print(unifiedDiff(diffPath(r.path), r.source, r.result.Output, r.result.Edits, t.diffContext))
+	case modeDryRun:
+		fmt.Printf("  %s (%d replacement(s))\n", r.path, n)
+	case modeWrite:
+		if r.writeErr != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: writing %s: %v\n", r.path, r.writeErr)
+			return
+		}
+		fmt.Printf("  ✓ %s (%d replacement(s))\n", r.path, n)
+	default:
+		// No -w flag: print to stdout (only useful for single files).
+		os.Stdout.Write(r.result.Output)
+	}
+}
+
+func (t *textReporter) summary(s summary) {
+	if s.Unsupported > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d file(s) use import attribute syntax that %s cannot run\n", s.Unsupported, t.plan.target)
+	}
+	if s.Held > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d edit(s) below -min-confidence left for manual review\n", s.Held)
+	}
+	if s.Unmigratable > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d computed import() option key(s) left for manual review\n", s.Unmigratable)
+	}
+	if len(t.parseErrors) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d file(s) have parse errors; imports in them may have been missed:\n", len(t.parseErrors))
+		for _, r := range t.parseErrors {
+			for _, e := range r.parseErrors {
+				fmt.Fprintf(os.Stderr, "  %s:%d:%d: %s\n", r.path, e.StartPoint.Row+1, e.StartPoint.Column+1, parseErrorMessage(e))
+			}
+		}
+	}
+
+	// When no migration can satisfy the target nothing is counted, so
+	// only the unsupported files above are reported.
+	switch t.mode {
+	case modeDryRun, modeWrite, modeDiff:
+		if !t.plan.unsafe() {
+			fmt.Fprintf(os.Stderr, "\n%d file(s) with %d total replacement(s)\n", s.ChangedFiles, s.Replacements)
+		}
+		if s.Failures > 0 {
+			fmt.Fprintf(os.Stderr, "%d file(s) could not be migrated\n", s.Failures)
+		}
+	case modeCheck:
+		if !t.plan.unsafe() {
+			fmt.Fprintf(os.Stderr, "\n%d file(s) with %d %s remaining\n", s.ChangedFiles, s.Replacements, clauseNoun(t.plan))
+		}
+		if s.Failures > 0 {
+			fmt.Fprintf(os.Stderr, "%d file(s) could not be checked\n", s.Failures)
+		}
+	default:
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d file(s) parsed, %d skipped by prefilter\n", s.Parsed, s.Prefiltered)
+}
+
+// clauseNoun names what check mode counts for p.
+func clauseNoun(p plan) string {
+	if p.custom != nil {
+		return "edit(s)"
+	}
+	if p.strip {
+		return "import attribute clause(s)"
+	}
+	if p.dir == transform.WithToAssert {
+		return "import attribute(s)"
+	}
+	return "import assertion(s)"
+}
+
+// parseErrorMessage describes a parse error.
+func parseErrorMessage(e transform.ParseError) string {
+	if e.Missing != "" {
+		return fmt.Sprintf("missing `%s`", e.Missing)
+	}
+	return "syntax error"
+}
+
+// computedKeyMessage describes the computed key f, which the migration
+// for p cannot check.
+func computedKeyMessage(f transform.Finding, p plan) string {
+	return fmt.Sprintf("has a computed key `%s`; check by hand that it is not `%s`", f.Text, p.dir.From())
+}
+
+// editMessage describes, for check mode, what applying the rule edit e
+// would fix.
+func editMessage(e transform.Edit) string {
+	switch e.Rule {
+	case transform.StripRule().Name():
+		return fmt.Sprintf("has an import attribute clause; remove `%s`", clauseText(e.Original))
+	case transform.AssertToWith.String(), transform.WithToAssert.String():
+		return fmt.Sprintf("uses `%s`; migrate to `%s`", e.Original, e.Replacement)
+	case transform.AddAttributesRule(transform.AssertToWith, nil).Name():
+		return fmt.Sprintf("has no import attributes; add `%s`", clauseText(e.Replacement))
+	default:
+		return fmt.Sprintf("matches rule %s; replace `%s` with `%s`", e.Rule, e.Original, e.Replacement)
+	}
+}
+
+// clauseText trims the separators a removal takes along with a clause.
+func clauseText(s string) string {
+	return strings.Trim(s, ", \t\r\n")
+}
+
+// jsonPosition is a 1-based line and column; columns count bytes.
+type jsonPosition struct {
+	Line   uint `json:"line"`
+	Column uint `json:"column"`
+}
+
+// jsonEdit describes one replacement, or in check mode one remaining
+// assertion.
+type jsonEdit struct {
+	Kind        string       `json:"kind"`
+	Strategy    string       `json:"strategy,omitempty"`
+	Start       jsonPosition `json:"start"`
+	End         jsonPosition `json:"end"`
+	StartByte   uint         `json:"startByte"`
+	EndByte     uint         `json:"endByte"`
+	Original    string       `json:"original"`
+	Replacement string       `json:"replacement,omitempty"`
+	Confidence  string       `json:"confidence,omitempty"`
+	// Rule names the rule behind the edit when the plan has rules.
+	Rule string `json:"rule,omitempty"`
+}
+
+// jsonFile is the report for a single file.
+type jsonFile struct {
+	Type         string     `json:"type,omitempty"`
+	Path         string     `json:"path"`
+	Language     string     `json:"language"`
+	Replacements int        `json:"replacements"`
+	Written      bool       `json:"written,omitempty"`
+	Prefiltered  bool       `json:"prefiltered,omitempty"`
+	Edits        []jsonEdit `json:"edits"`
+	// Held lists the edits below -min-confidence, which were not
+	// applied.
+	Held []jsonEdit `json:"held,omitempty"`
+	// Unsupported lists keywords the -target runtime cannot run.
+	Unsupported []jsonEdit `json:"unsupported,omitempty"`
+	// Unmigratable lists the computed import() option keys.
+	Unmigratable []jsonEdit `json:"unmigratable,omitempty"`
+	// ParseErrors lists where the grammar could not parse the file.
+	ParseErrors []jsonParseError `json:"parseErrors,omitempty"`
+	Diff        string           `json:"diff,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// jsonParseError locates an ERROR or MISSING node.
+type jsonParseError struct {
+	Start     jsonPosition `json:"start"`
+	End       jsonPosition `json:"end"`
+	StartByte uint         `json:"startByte"`
+	EndByte   uint         `json:"endByte"`
+	// Missing is the token the parser assumed, if any.
+	Missing string `json:"missing,omitempty"`
+}
+
+// jsonSummary wraps summary with a record type for JSON Lines output.
+type jsonSummary struct {
+	Type string `json:"type,omitempty"`
+	summary
+}
+
+// jsonReporter emits machine-readable reports. With lines set, each file
+// and the final summary are written as separate JSON Lines records
+// tagged with a "type" field as soon as they are available; otherwise
+// a single JSON document holding all files and the summary is written
+// at the end.
+type jsonReporter struct {
+	w           io.Writer
+	mode        mode
+	plan        plan
+	diffContext int
+	lines       bool
+	files       []jsonFile
+}
+
+func (j *jsonReporter) file(r fileResult) {
+	f := jsonFile{
+		Path:         r.path,
+		Language:     r.lang.String(),
+		Replacements: r.count(),
+		Edits:        []jsonEdit{},
+	}
+
+	switch {
+	case r.err != nil:
+		f.Error = r.err.Error()
+	case r.writeErr != nil:
+		f.Error = "writing: " + r.writeErr.Error()
+	case j.mode == modeWrite && f.Replacements > 0:
+		f.Written = true
+	}
+
+	if r.result != nil {
+		f.Prefiltered = r.result.Prefiltered
+		for _, e := range r.result.Edits {
+			f.Edits = append(f.Edits, j.edit(e))
+		}
+		for _, e := range r.result.Held {
+			f.Held = append(f.Held, j.edit(e))
+		}
+		if j.mode == modeDiff {
+			f.Diff = unifiedDiff(diffPath(r.path), r.source, r.result.Output, r.result.Edits, j.diffContext)
+		}
+	}
+	for _, fd := range r.findings {
+		e := findingEdit(fd, j.plan.dir)
+		je := newJSONEdit(fd.Kind, fd.Strategy.String(), fd.StartPoint, fd.EndPoint, fd.StartByte, fd.EndByte, e.Original, e.Replacement)
+		je.Confidence = fd.Strategy.Confidence().String()
+		f.Edits = append(f.Edits, je)
+	}
+
+	for _, fd := range r.unsupported {
+		f.Unsupported = append(f.Unsupported, newJSONEdit(fd.Kind, fd.Strategy.String(), fd.StartPoint, fd.EndPoint, fd.StartByte, fd.EndByte, fd.Text, ""))
+	}
+
+	for _, fd := range r.unmigratable {
+		f.Unmigratable = append(f.Unmigratable, newJSONEdit(fd.Kind, fd.Strategy.String(), fd.StartPoint, fd.EndPoint, fd.StartByte, fd.EndByte, fd.Text, ""))
+	}
+
+	for _, e := range r.parseErrors {
+		f.ParseErrors = append(f.ParseErrors, jsonParseError{
+			Start:     jsonPosition{Line: e.StartPoint.Row + 1, Column: e.StartPoint.Column + 1},
+			End:       jsonPosition{Line: e.EndPoint.Row + 1, Column: e.EndPoint.Column + 1},
+			StartByte: e.StartByte,
+			EndByte:   e.EndByte,
+			Missing:   e.Missing,
+		})
+	}
+
+	if j.lines {
+		f.Type = "file"
+		j.encode(f)
+		return
+	}
+	j.files = append(j.files, f)
+}
+
+// edit converts an edit from a migration result.
+func (j *jsonReporter) edit(e transform.Edit) jsonEdit {
+	je := newJSONEdit(e.Kind, "", e.StartPoint, e.EndPoint, e.StartByte, e.EndByte, e.Original, e.Replacement)
+	je.Confidence = e.Confidence.String()
+	if j.plan.rules != nil {
+		je.Rule = e.Rule
+	}
+	return je
+}
+
+func (j *jsonReporter) summary(s summary) {
+	if j.lines {
+		j.encode(jsonSummary{Type: "summary", summary: s})
+		return
+	}
+
+	files := j.files
+	if files == nil {
+		files = []jsonFile{}
+	}
+	j.encode(struct {
+		Files   []jsonFile `json:"files"`
+		Summary summary    `json:"summary"`
+	}{files, s})
+}
+
+// encode writes v as a single line of JSON.
+func (j *jsonReporter) encode(v any) {
+	if err := json.NewEncoder(j.w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: writing report: %v\n", err)
+	}
+}
+
+// newJSONEdit converts tree-sitter's zero-based points to 1-based
+// positions.
+func newJSONEdit(kind transform.EditKind, strategy string, start, end transform.Point, startByte, endByte uint, original, replacement string) jsonEdit {
+	return jsonEdit{
+		Kind:        kind.String(),
+		Strategy:    strategy,
+		Start:       jsonPosition{Line: start.Row + 1, Column: start.Column + 1},
+		End:         jsonPosition{Line: end.Row + 1, Column: end.Column + 1},
+		StartByte:   startByte,
+		EndByte:     endByte,
+		Original:    original,
+		Replacement: replacement,
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestJSONReporter_Lines(t *testing.T) {
+	source := []byte(`import a from './a.json' assert { type: 'json' };`)
+	result, err := transform.MigrateAssertToWith(source, transform.JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep := &jsonReporter{w: &buf, mode: modeDryRun, lines: true}
+
+	results := []fileResult{
+		{path: "a.js", lang: transform.JavaScript, source: source, result: result},
+		{path: "b.ts", lang: transform.TypeScript, err: errors.New("boom")},
+	}
+	var sum summary
+	for _, r := range results {
+		sum.add(r)
+		rep.file(r)
+	}
+	rep.summary(sum)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(lines), buf.String())
+	}
+
+	var first jsonFile
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decoding file record: %v", err)
+	}
+	if first.Type != "file" || first.Path != "a.js" || first.Language != "javascript" || first.Replacements != 1 {
+		t.Errorf("unexpected file record: %+v", first)
+	}
+	if len(first.Edits) != 1 {
+		t.Fatalf("edit count: got %d, want 1", len(first.Edits))
+	}
+	e := first.Edits[0]
+	if e.Kind != "static-import" || e.Start != (jsonPosition{Line: 1, Column: 26}) || e.Replacement != "with" {
+		t.Errorf("unexpected edit: %+v", e)
+	}
+
+	var second jsonFile
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("decoding file record: %v", err)
+	}
+	if second.Error != "boom" {
+		t.Errorf("error: got %q, want %q", second.Error, "boom")
+	}
+
+	var last jsonSummary
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatalf("decoding summary record: %v", err)
+	}
+	want := summary{Files: 2, ChangedFiles: 1, Replacements: 1, Failures: 1}
+	if last.Type != "summary" || last.summary != want {
+		t.Errorf("summary: got %+v, want %+v", last, want)
+	}
+}
+
+func TestJSONReporter_Held(t *testing.T) {
+	// The JavaScript grammar only recovers the re-export's clause through
+	// an ERROR node, so its edit is low confidence.
+	source := []byte(`import a from './a.json' with { type: 'json' };
+export { b } from './b.json' assert { type: 'json' };
+const c = await import('./c.json', { assert: { type: 'json' } });
+`)
+	mig := transform.NewMigrator(transform.WithMinConfidence(transform.HighConfidence))
+	defer mig.Close()
+	result, err := mig.MigrateAssertToWith(source, transform.JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep := &jsonReporter{w: &buf, mode: modeWrite, lines: true}
+	r := fileResult{path: "a.js", lang: transform.JavaScript, source: source, result: result}
+	var sum summary
+	sum.add(r)
+	rep.file(r)
+
+	var f jsonFile
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatalf("decoding file record: %v", err)
+	}
+	if f.Replacements != 1 || len(f.Edits) != 1 || f.Edits[0].Confidence != "high" {
+		t.Errorf("unexpected applied edits: %+v", f.Edits)
+	}
+	if len(f.Held) != 1 || f.Held[0].Start.Line != 2 || f.Held[0].Confidence != "low" {
+		t.Errorf("unexpected held edits: %+v", f.Held)
+	}
+	if sum.Held != 1 || sum.Replacements != 1 {
+		t.Errorf("summary: got %+v, want 1 held and 1 replacement", sum)
+	}
+}
+
+func TestJSONReporter_Unmigratable(t *testing.T) {
+	source := []byte("const key = 'assert';\nconst a = await import('./a.json', { [key]: { type: 'json' } });\n")
+	mig := transform.NewMigrator()
+	defer mig.Close()
+	result, err := mig.MigrateAssertToWith(source, transform.JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep := &jsonReporter{w: &buf, mode: modeWrite, lines: true}
+	r := fileResult{path: "a.js", lang: transform.JavaScript, source: source, result: result}.checkSyntax(plan{})
+	var sum summary
+	sum.add(r)
+	rep.file(r)
+
+	var f jsonFile
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatalf("decoding file record: %v", err)
+	}
+	if len(f.Unmigratable) != 1 || f.Unmigratable[0].Original != "[key]" || f.Unmigratable[0].Strategy != "computed-key" {
+		t.Errorf("unexpected unmigratable keys: %+v", f.Unmigratable)
+	}
+	if sum.Unmigratable != 1 || sum.Replacements != 0 {
+		t.Errorf("summary: got %+v, want 1 unmigratable key and no replacements", sum)
+	}
+}
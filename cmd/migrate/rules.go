@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// ruleFile is the format of a -rules file:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "name": "lodash-es",
+//	      "description": "Import lodash-es instead of lodash",
+//	      "query": "(import_statement source: (string (string_fragment) @pkg) (#eq? @pkg \"lodash\"))",
+//	      "capture": "pkg",
+//	      "template": "lodash-es",
+//	      "languages": ["typescript", "tsx"]
+//	    }
+//	  ]
+//	}
+//
+// See transform.QuerySpec for the meaning of each field.
+type ruleFile struct {
+	Rules []ruleSpec `json:"rules"`
+}
+
+type ruleSpec struct {
+	Name string `json:"name"`
+	// Description is shown in SARIF reports; it defaults to the name.
+	Description string   `json:"description"`
+	Query       string   `json:"query"`
+	Capture     string   `json:"capture"`
+	Template    string   `json:"template"`
+	Languages   []string `json:"languages"`
+}
+
+// loadedRule is a compiled rule from a -rules file.
+type loadedRule struct {
+	rule        *transform.QueryRule
+	description string
+}
+
+// loadRules compiles the rules in each file. Rule names must be unique
+// and must not shadow a built-in rule.
+func loadRules(paths []string) ([]loadedRule, error) {
+	var rules []loadedRule
+	names := make(map[string]string)
+	fail := func(err error) ([]loadedRule, error) {
+		for _, r := range rules {
+			r.rule.Close()
+		}
+		return nil, err
+	}
+
+	for _, path := range paths {
+		if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+			return fail(fmt.Errorf("%s: YAML rule files are not supported; use JSON", path))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fail(err)
+		}
+
+		var f ruleFile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return fail(fmt.Errorf("%s: %w", path, err))
+		}
+
+		for _, s := range f.Rules {
+			if prev, dup := names[s.Name]; dup {
+				return fail(fmt.Errorf("%s: rule %s is already defined in %s", path, s.Name, prev))
+			}
+			if _, builtin := transform.Lookup(s.Name); builtin {
+				return fail(fmt.Errorf("%s: rule %s has the name of a built-in rule", path, s.Name))
+			}
+			names[s.Name] = path
+
+			spec := transform.QuerySpec{Name: s.Name, Query: s.Query, Capture: s.Capture, Template: s.Template}
+			for _, name := range s.Languages {
+				lang, err := transform.ParseLanguage(name)
+				if err != nil {
+					return fail(fmt.Errorf("%s: rule %s: %w", path, s.Name, err))
+				}
+				spec.Languages = append(spec.Languages, lang)
+			}
+			r, err := transform.NewQueryRule(spec)
+			if err != nil {
+				return fail(fmt.Errorf("%s: %w", path, err))
+			}
+
+			description := s.Description
+			if description == "" {
+				description = s.Name
+			}
+			rules = append(rules, loadedRule{rule: r, description: description})
+		}
+	}
+	return rules, nil
+}
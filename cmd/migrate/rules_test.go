@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+const jsonDirRules = `{
+  "rules": [
+    {
+      "name": "json-dir",
+      "description": "JSON data moved to data/",
+      "query": "(import_statement source: (string (string_fragment) @path) (#match? @path \"^[^./].*\\\\.json$\"))",
+      "capture": "path",
+      "template": "./data/{{path}}"
+    }
+  ]
+}`
+
+func writeRuleFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := loadRules([]string{writeRuleFile(t, "rules.json", jsonDirRules)})
+	if err != nil {
+		t.Fatalf("loadRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].rule.Name() != "json-dir" || rules[0].description != "JSON data moved to data/" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	p := plan{dir: transform.AssertToWith, custom: rules}
+	p.rules = planRules(p, nil)
+
+	src := filepath.Join(t.TempDir(), "a.js")
+	source := "import a from 'a.json' assert { type: 'json' };\n"
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mig := transform.NewMigrator()
+	defer mig.Close()
+
+	r := processFile(mig, src, modeCheck, p)
+	if r.err != nil {
+		t.Fatalf("processFile: %v", r.err)
+	}
+	if r.count() != 2 {
+		t.Fatalf("count: got %d, want 2", r.count())
+	}
+	if got, want := editMessage(r.result.Edits[0]), "matches rule json-dir; replace `a.json` with `./data/a.json`"; got != want {
+		t.Errorf("message: got %q, want %q", got, want)
+	}
+	if got, want := editMessage(r.result.Edits[1]), "uses `assert`; migrate to `with`"; got != want {
+		t.Errorf("message: got %q, want %q", got, want)
+	}
+	if data, _ := os.ReadFile(src); string(data) != source {
+		t.Error("check mode must not rewrite the file")
+	}
+}
+
+func TestLoadRules_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{
+			name:    "yaml",
+			file:    "rules.yaml",
+			content: "rules: []\n",
+			want:    "YAML rule files are not supported",
+		},
+		{
+			name:    "unknown field",
+			file:    "rules.json",
+			content: `{"rules": [{"name": "r", "query": "(string) @s", "capture": "s", "replace": "x"}]}`,
+			want:    `unknown field "replace"`,
+		},
+		{
+			name:    "duplicate name",
+			file:    "rules.json",
+			content: `{"rules": [{"name": "r", "query": "(string) @s", "capture": "s"}, {"name": "r", "query": "(string) @s", "capture": "s"}]}`,
+			want:    "rule r is already defined",
+		},
+		{
+			name:    "built-in name",
+			file:    "rules.json",
+			content: `{"rules": [{"name": "assert-to-with", "query": "(string) @s", "capture": "s"}]}`,
+			want:    "name of a built-in rule",
+		},
+		{
+			name:    "unknown language",
+			file:    "rules.json",
+			content: `{"rules": [{"name": "r", "query": "(string) @s", "capture": "s", "languages": ["jsx"]}]}`,
+			want:    `unknown language "jsx"`,
+		},
+		{
+			name:    "bad query",
+			file:    "rules.json",
+			content: `{"rules": [{"name": "r", "query": "(string @s", "capture": "s"}]}`,
+			want:    "rule r: javascript query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadRules([]string{writeRuleFile(t, tt.file, tt.content)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
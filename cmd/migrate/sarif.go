@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// SARIF log identifiers and the rule ids for each migration direction.
+const (
+	sarifSchema        = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion       = "2.1.0"
+	sarifRuleID        = "import-assertion"
+	sarifReverseRuleID = "import-attribute"
+	sarifUnsupportedID = "unsupported-import-attribute"
+	sarifStripRuleID   = "import-attribute-clause"
+)
+
+// The types below model the subset of SARIF 2.1.0 the tool emits.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	ColumnKind  string            `json:"columnKind"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	HelpURI              string             `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   uint `json:"startLine"`
+	StartColumn uint `json:"startColumn"`
+	EndLine     uint `json:"endLine"`
+	EndColumn   uint `json:"endColumn"`
+	ByteOffset  uint `json:"byteOffset"`
+	ByteLength  uint `json:"byteLength"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// sarifReporter collects every assertion as a SARIF result and writes a
+// single log when the run completes. Each result carries a fix that
+// performs the same byte-range replacement as -w.
+type sarifReporter struct {
+	w             io.Writer
+	plan          plan
+	results       []sarifResult
+	notifications []sarifNotification
+}
+
+func (s *sarifReporter) file(r fileResult) {
+	artifact := sarifArtifactLocation{URI: sarifURI(r.path)}
+
+	if r.err != nil || r.writeErr != nil {
+		err := r.err
+		if err == nil {
+			err = fmt.Errorf("writing: %w", r.writeErr)
+		}
+		s.notifications = append(s.notifications, sarifNotification{
+			Level:     "error",
+			Message:   sarifMessage{Text: err.Error()},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifact}}},
+		})
+		if r.err != nil {
+			return
+		}
+	}
+
+	for _, e := range r.parseErrors {
+		region := sarifRegionFor(r.source, transform.Edit{
+			StartByte: e.StartByte, EndByte: e.EndByte, StartPoint: e.StartPoint, EndPoint: e.EndPoint,
+		})
+		s.notifications = append(s.notifications, sarifNotification{
+			Level:   "warning",
+			Message: sarifMessage{Text: fmt.Sprintf("Parse error (%s); imports here may have been missed.", parseErrorMessage(e))},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifact,
+				Region:           &region,
+			}}},
+		})
+	}
+
+	for _, f := range r.unmigratable {
+		region := sarifRegionFor(r.source, findingEdit(f, s.plan.dir))
+		s.notifications = append(s.notifications, sarifNotification{
+			Level:   "warning",
+			Message: sarifMessage{Text: fmt.Sprintf("%s %s. It was left unchanged.", f.Kind, computedKeyMessage(f, s.plan))},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifact,
+				Region:           &region,
+			}}},
+		})
+	}
+
+	for _, f := range r.unsupported {
+		region := sarifRegionFor(r.source, findingEdit(f, s.plan.dir))
+		s.results = append(s.results, sarifResult{
+			RuleID: sarifUnsupportedID,
+			Level:  "error",
+			Message: sarifMessage{Text: fmt.Sprintf(
+				"%s uses `%s`, which %s cannot run.", f.Kind, f.Text, s.plan.target)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifact,
+				Region:           &region,
+			}}},
+		})
+	}
+
+	rule := sarifRuleFor(s.plan)
+	edits := editsOf(r, s.plan.dir)
+	applied := len(edits)
+	if r.result != nil {
+		edits = append(edits, r.result.Held...)
+	}
+	for i, e := range edits {
+		region := sarifRegionFor(r.source, e)
+		ruleID := rule.ID
+		message := fmt.Sprintf("%s uses `%s`; use `%s` instead.", e.Kind, e.Original, e.Replacement)
+		fix := fmt.Sprintf("Replace `%s` with `%s`", e.Original, e.Replacement)
+		custom, isCustom := s.plan.customRule(e.Rule)
+		switch {
+		case isCustom:
+			ruleID = e.Rule
+			message = fmt.Sprintf("%s: replace `%s` with `%s`.", custom.description, e.Original, e.Replacement)
+		case e.StartByte == e.EndByte:
+			clause := clauseText(e.Replacement)
+			message = fmt.Sprintf("%s has no import attributes; add `%s`.", e.Kind, clause)
+			fix = fmt.Sprintf("Insert `%s`", clause)
+		case e.Replacement == "":
+			clause := clauseText(e.Original)
+			message = fmt.Sprintf("%s has an import attribute clause; remove `%s`.", e.Kind, clause)
+			fix = fmt.Sprintf("Remove `%s`", clause)
+		}
+		if i >= applied {
+			message += fmt.Sprintf(" Not applied: the match has %s confidence; review it by hand.", e.Confidence)
+		}
+		s.results = append(s.results, sarifResult{
+			RuleID:  ruleID,
+			Level:   "warning",
+			Message: sarifMessage{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifact,
+				Region:           &region,
+			}}},
+			Fixes: []sarifFix{{
+				Description: sarifMessage{Text: fix},
+				ArtifactChanges: []sarifArtifactChange{{
+					ArtifactLocation: artifact,
+					Replacements: []sarifReplacement{{
+						DeletedRegion:   region,
+						InsertedContent: sarifMessage{Text: e.Replacement},
+					}},
+				}},
+			}},
+		})
+	}
+}
+
+func (s *sarifReporter) summary(sum summary) {
+	results := s.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "import-attr-migrator",
+				InformationURI: "https://github.com/netlify/import-attr-migrator",
+				Rules:          s.rules(),
+			}},
+			Invocations: []sarifInvocation{{
+				ExecutionSuccessful:        sum.Failures == 0,
+				ToolExecutionNotifications: s.notifications,
+			}},
+			ColumnKind: "utf16CodeUnits",
+			Results:    results,
+		}},
+	}
+
+	enc := json.NewEncoder(s.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: writing report: %v\n", err)
+	}
+}
+
+// rules returns the rules the run can report.
+func (s *sarifReporter) rules() []sarifRule {
+	rules := []sarifRule{sarifRuleFor(s.plan)}
+	if s.plan.unsafe() {
+		rules = append(rules, sarifRule{
+			ID:               sarifUnsupportedID,
+			Name:             "UnsupportedImportAttributeSyntax",
+			ShortDescription: sarifMessage{Text: "Import attributes on a target that supports neither syntax"},
+			FullDescription: sarifMessage{Text: "The -target runtime accepts neither import assertions " +
+				"(`assert { ... }`) nor import attributes (`with { ... }`), so these imports cannot be migrated."},
+			HelpURI:              "https://github.com/tc39/proposal-import-attributes",
+			DefaultConfiguration: sarifConfiguration{Level: "error"},
+		})
+	}
+	for _, c := range s.plan.custom {
+		rules = append(rules, sarifRule{
+			ID:                   c.rule.Name(),
+			Name:                 c.rule.Name(),
+			ShortDescription:     sarifMessage{Text: c.description},
+			FullDescription:      sarifMessage{Text: c.description},
+			HelpURI:              "https://github.com/netlify/import-attr-migrator#rule-files",
+			DefaultConfiguration: sarifConfiguration{Level: "warning"},
+		})
+	}
+	return rules
+}
+
+// sarifRuleFor describes the rule reported for p.
+func sarifRuleFor(p plan) sarifRule {
+	if p.strip {
+		return sarifRule{
+			ID:               sarifStripRuleID,
+			Name:             "ImportAttributeClause",
+			ShortDescription: sarifMessage{Text: "Import attribute clause in bundler-only code"},
+			FullDescription: sarifMessage{Text: "This code is only consumed by a bundler, which does not need " +
+				"import assertions (`assert { ... }`) or import attributes (`with { ... }`)."},
+			HelpURI:              "https://github.com/tc39/proposal-import-attributes",
+			DefaultConfiguration: sarifConfiguration{Level: "warning"},
+		}
+	}
+	if p.dir == transform.WithToAssert {
+		return sarifRule{
+			ID:               sarifReverseRuleID,
+			Name:             "UnsupportedImportAttribute",
+			ShortDescription: sarifMessage{Text: "Import attribute (`with`) on an assertion-only target"},
+			FullDescription: sarifMessage{Text: "The target runtimes only understand legacy import " +
+				"assertions (`assert { ... }`) and reject import attributes (`with { ... }`)."},
+			HelpURI:              "https://github.com/tc39/proposal-import-attributes",
+			DefaultConfiguration: sarifConfiguration{Level: "warning"},
+		}
+	}
+	return sarifRule{
+		ID:               sarifRuleID,
+		Name:             "LegacyImportAssertion",
+		ShortDescription: sarifMessage{Text: "Legacy import assertion (`assert`)"},
+		FullDescription: sarifMessage{Text: "Import assertions (`assert { ... }`) were replaced by " +
+			"import attributes (`with { ... }`) and are rejected by current runtimes."},
+		HelpURI:              "https://github.com/tc39/proposal-import-attributes",
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	}
+}
+
+// editsOf returns the edits made to a file, or in check mode the edits
+// that migrating it in dir would make.
+func editsOf(r fileResult, dir transform.Direction) []transform.Edit {
+	if r.result != nil {
+		return r.result.Edits
+	}
+	edits := make([]transform.Edit, len(r.findings))
+	for i, f := range r.findings {
+		edits[i] = findingEdit(f, dir)
+	}
+	return edits
+}
+
+// findingEdit returns the edit that migrating f in dir would make.
+func findingEdit(f transform.Finding, dir transform.Direction) transform.Edit {
+	replacement := dir.To()
+	if f.Strategy == transform.MissingAttributes {
+		replacement = f.Insertion
+	}
+	return transform.Edit{
+		StartByte:   f.StartByte,
+		EndByte:     f.EndByte,
+		StartPoint:  f.StartPoint,
+		EndPoint:    f.EndPoint,
+		Original:    f.Text,
+		Replacement: replacement,
+		Kind:        f.Kind,
+	}
+}
+
+// sarifURI returns the artifact URI for path: a relative, slash-separated
+// reference for relative paths and a file URI for absolute ones.
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		return "file://" + filepath.ToSlash(path)
+	}
+	return diffPath(path)
+}
+
+// sarifRegionFor converts an edit's byte-based points to SARIF's 1-based
+// lines and UTF-16 columns.
+func sarifRegionFor(source []byte, e transform.Edit) sarifRegion {
+	return sarifRegion{
+		StartLine:   e.StartPoint.Row + 1,
+		StartColumn: utf16Column(source, e.StartByte, e.StartPoint.Column),
+		EndLine:     e.EndPoint.Row + 1,
+		EndColumn:   utf16Column(source, e.EndByte, e.EndPoint.Column),
+		ByteOffset:  e.StartByte,
+		ByteLength:  e.EndByte - e.StartByte,
+	}
+}
+
+// utf16Column returns the 1-based UTF-16 column of the byte at offset,
+// given its zero-based byte column on the line.
+func utf16Column(source []byte, offset, byteColumn uint) uint {
+	if offset > uint(len(source)) || byteColumn > offset {
+		return byteColumn + 1
+	}
+	line := source[offset-byteColumn : offset]
+
+	var col uint
+	for len(line) > 0 {
+		r, size := utf8.DecodeRune(line)
+		if utf16.RuneLen(r) == 2 {
+			col += 2
+		} else {
+			col++
+		}
+		line = line[size:]
+	}
+	return col + 1
+}
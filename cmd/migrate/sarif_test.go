@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestSARIFReporter(t *testing.T) {
+	// The emoji is two UTF-16 code units but four bytes, so the SARIF
+	// column must differ from the byte column.
+	source := []byte("/* 😀 */ import a from './a.json' assert { type: 'json' };\n")
+	findings, err := transform.Find(source, transform.JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep := &sarifReporter{w: &buf, plan: plan{dir: transform.AssertToWith}}
+	r := fileResult{path: "src/a.js", lang: transform.JavaScript, source: source, findings: findings}
+	var sum summary
+	sum.add(r)
+	rep.file(r)
+	rep.summary(sum)
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("decoding SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log header: version %q, %d run(s)", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if len(run.Results) != 1 {
+		t.Fatalf("result count: got %d, want 1", len(run.Results))
+	}
+	res := run.Results[0]
+	if res.RuleID != sarifRuleID {
+		t.Errorf("rule id: got %q, want %q", res.RuleID, sarifRuleID)
+	}
+
+	loc := res.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "src/a.js" {
+		t.Errorf("uri: got %q", loc.ArtifactLocation.URI)
+	}
+	// "/* 😀 */ import a from './a.json' " is 36 bytes but 34 UTF-16 units.
+	want := sarifRegion{StartLine: 1, StartColumn: 35, EndLine: 1, EndColumn: 41, ByteOffset: 36, ByteLength: 6}
+	if *loc.Region != want {
+		t.Errorf("region: got %+v, want %+v", *loc.Region, want)
+	}
+
+	if len(res.Fixes) != 1 {
+		t.Fatalf("fix count: got %d, want 1", len(res.Fixes))
+	}
+	repl := res.Fixes[0].ArtifactChanges[0].Replacements[0]
+	if repl.DeletedRegion != want || repl.InsertedContent.Text != "with" {
+		t.Errorf("fix: got %+v", repl)
+	}
+	if !run.Invocations[0].ExecutionSuccessful {
+		t.Error("run should be marked successful")
+	}
+}
+
+func TestSARIFReporter_Reverse(t *testing.T) {
+	source := []byte("import a from './a.json' with { type: 'json' };\n")
+	findings, err := transform.NewMigrator().FindDirection(source, transform.JavaScript, transform.WithToAssert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rep := &sarifReporter{w: &buf, plan: plan{dir: transform.WithToAssert}}
+	r := fileResult{path: "a.js", lang: transform.JavaScript, source: source, findings: findings}
+	var sum summary
+	sum.add(r)
+	rep.file(r)
+	rep.summary(sum)
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("decoding SARIF: %v", err)
+	}
+	run := log.Runs[0]
+	if got := run.Tool.Driver.Rules[0].ID; got != sarifReverseRuleID {
+		t.Errorf("rule: got %q, want %q", got, sarifReverseRuleID)
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("result count: got %d, want 1", len(run.Results))
+	}
+	repl := run.Results[0].Fixes[0].ArtifactChanges[0].Replacements[0]
+	if repl.InsertedContent.Text != "assert" {
+		t.Errorf("inserted content: got %q, want %q", repl.InsertedContent.Text, "assert")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+// sniffSize is how much of an extensionless file is read to tell
+// whether it is a script.
+const sniffSize = 4096
+
+var (
+	// tsPragma matches the `// @ts-` comments TypeScript reads in
+	// JavaScript files.
+	tsPragma = regexp.MustCompile(`(?m)^[ \t]*//[ \t]*@ts-(check|nocheck|ignore|expect-error)\b`)
+	// esmSyntax matches a static import or an export declaration at the
+	// start of a line. Imports must name a quoted module, so that e.g.
+	// Python's `import os, sys` does not match.
+	esmSyntax = regexp.MustCompile(`(?m)^[ \t]*(import[ \t]*['"]|import[ \t][^;'"\n]*\bfrom[ \t]*['"]|export[ \t]+(default|const|let|var|function|class|async|\{|\*))`)
+)
+
+// interpreters maps the shebang interpreters of JavaScript and
+// TypeScript runtimes to the grammar for their scripts.
+var interpreters = map[string]transform.Language{
+	"node":        transform.JavaScript,
+	"nodejs":      transform.JavaScript,
+	"bun":         transform.JavaScript,
+	"zx":          transform.JavaScript,
+	"deno":        transform.TypeScript,
+	"ts-node":     transform.TypeScript,
+	"ts-node-esm": transform.TypeScript,
+	"tsx":         transform.TypeScript,
+}
+
+// sniffScript reports whether content, the start of an extensionless
+// file, is a JavaScript or TypeScript script and which grammar suits it.
+// A shebang decides by its interpreter. Otherwise a `// @ts-` pragma or
+// ESM import/export syntax marks a JavaScript module; the grammar
+// fallback takes care of any TypeScript in it.
+func sniffScript(content []byte) (transform.Language, bool) {
+	if len(content) > sniffSize {
+		content = content[:sniffSize]
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return 0, false
+	}
+
+	if line, ok := bytes.CutPrefix(content, []byte("#!")); ok {
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		return shebangLanguage(string(line))
+	}
+
+	if tsPragma.Match(content) || esmSyntax.Match(content) {
+		return transform.JavaScript, true
+	}
+	return 0, false
+}
+
+// shebangLanguage returns the grammar for the interpreter named by a
+// shebang line (without the `#!`), looking through `env` and its
+// options and variable assignments.
+func shebangLanguage(line string) (transform.Language, bool) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && path.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		for len(fields) > 0 && (strings.HasPrefix(fields[0], "-") || strings.Contains(fields[0], "=")) {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return 0, false
+	}
+	lang, ok := interpreters[path.Base(fields[0])]
+	return lang, ok
+}
+
+// isScript reports whether the extensionless file at path looks like a
+// script to sniffScript. Files that cannot be read are not scripts.
+func isScript(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+	_, ok := sniffScript(head[:n])
+	return ok
+}
+
+// languageFor determines the tree-sitter Language for a file from its
+// extension or, if it has none, from its content.
+func languageFor(path string, source []byte) transform.Language {
+	if filepath.Ext(path) == "" {
+		if lang, ok := sniffScript(source); ok {
+			return lang
+		}
+	}
+	return languageForFile(path)
+}
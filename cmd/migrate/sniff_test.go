@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/netlify/import-attr-migrator/transform"
+)
+
+func TestSniffScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    transform.Language
+		ok      bool
+	}{
+		{"node shebang", "#!/usr/bin/env node\nimport data from './data.json' assert { type: 'json' };\n", transform.JavaScript, true},
+		{"env options", "#!/usr/bin/env -S NODE_OPTIONS=--no-warnings node --experimental-json-modules\n", transform.JavaScript, true},
+		{"absolute interpreter", "#!/usr/local/bin/node\nconsole.log(1)\n", transform.JavaScript, true},
+		{"ts-node shebang", "#!/usr/bin/env ts-node\nconst x: number = 1;\n", transform.TypeScript, true},
+		{"deno shebang", "#!/usr/bin/env -S deno run --allow-read\n", transform.TypeScript, true},
+		{"shell shebang", "#!/bin/sh\nexport FOO=bar\n", 0, false},
+		{"python shebang", "#!/usr/bin/env python3\nimport os, sys\n", 0, false},
+		{"ts pragma", "// @ts-check\nconst fs = require('fs');\n", transform.JavaScript, true},
+		{"esm import", "import fs from 'node:fs';\n", transform.JavaScript, true},
+		{"side-effect import", "import './setup.js';\n", transform.JavaScript, true},
+		{"esm export", "export default function main() {}\n", transform.JavaScript, true},
+		{"python import", "import os, sys\nfrom x import y\n", 0, false},
+		{"plain text", "This project is licensed under the MIT license.\n", 0, false},
+		{"binary", "\x7fELF\x00\x00import x from 'y'", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sniffScript([]byte(tt.content))
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("sniffScript = %s, %v; want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCollectFiles_Sniff(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"bin/cli":     "#!/usr/bin/env node\nimport pkg from '../package.json' assert { type: 'json' };\n",
+		"bin/deploy":  "#!/bin/bash\necho deploy\n",
+		"bin/tool.js": "console.log(1);\n",
+		"LICENSE":     "MIT License\n",
+		"Makefile":    "all:\n\tgo build ./...\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	extSet := parseExtensions(".js")
+	for _, sniff := range []bool{false, true} {
+		files, err := collectFiles(root, extSet, walkOptions{recursive: true, sniff: sniff})
+		if err != nil {
+			t.Fatalf("collectFiles: %v", err)
+		}
+		want := []string{filepath.Join(root, "bin/tool.js")}
+		if sniff {
+			want = []string{filepath.Join(root, "bin/cli"), filepath.Join(root, "bin/tool.js")}
+		}
+		if !reflect.DeepEqual(files, want) {
+			t.Errorf("sniff=%v:\n  got:  %v\n  want: %v", sniff, files, want)
+		}
+	}
+}
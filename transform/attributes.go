@@ -0,0 +1,169 @@
+package transform
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// DefaultAttributeTypes maps `.json` specifiers to `type: 'json'`, which
+// native ESM loaders require before they will import a JSON module.
+var DefaultAttributeTypes = map[string]string{".json": "json"}
+
+// AddImportAttributes inserts `with { type: '...' }` into static imports,
+// re-exports and dynamic import() calls that have no attributes and whose
+// specifier ends in one of the extensions in types. types maps an
+// extension (".json", ".css") to the type value; nil means
+// DefaultAttributeTypes.
+//
+//	import pkg from './package.json'
+//	const data = await import('./data.json')
+//
+// become
+//
+//	import pkg from './package.json' with { type: 'json' }
+//	const data = await import('./data.json', { with: { type: 'json' } })
+//
+// The inserted type uses the specifier's quote style. Type-only imports
+// are left alone because TypeScript erases them. Each insertion is an
+// Edit whose StartByte equals its EndByte.
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func AddImportAttributes(source []byte, lang Language, types map[string]string) (*Result, error) {
+	return defaultMigrator.AddImportAttributes(source, lang, types)
+}
+
+// attributeClause returns the text inserted after a static specifier.
+func attributeClause(keyword, typ string, quote byte) string {
+	return " " + keyword + " { type: " + string(quote) + typ + string(quote) + " }"
+}
+
+// attributeOptions returns the text inserted after a dynamic import()
+// specifier.
+func attributeOptions(keyword, typ string, quote byte) string {
+	return ", { " + keyword + ": { type: " + string(quote) + typ + string(quote) + " } }"
+}
+
+// mayNeedAttributes reports whether source mentions any extension in
+// types. Like mayContainKeyword it never rejects a source that
+// collectMissingAttributes would change.
+func mayNeedAttributes(source []byte, types map[string]string) bool {
+	for ext := range types {
+		if bytes.Contains(source, []byte(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectMissingAttributes walks the CST and records an insertion for
+// every attribute-less import of a specifier listed in types. keyword is
+// the attribute keyword to insert ("with" or "assert").
+func collectMissingAttributes(node *tree_sitter.Node, source []byte, keyword string, types map[string]string, out *[]Finding) {
+	if node == nil {
+		return
+	}
+
+	if f, ok := matchMissingAttributes(node, source, keyword, types); ok {
+		*out = append(*out, f)
+		return
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		collectMissingAttributes(node.Child(i), source, keyword, types, out)
+	}
+}
+
+// matchMissingAttributes reports whether node is an attribute-less
+// import of a specifier listed in types.
+func matchMissingAttributes(node *tree_sitter.Node, source []byte, keyword string, types map[string]string) (Finding, bool) {
+	switch node.Kind() {
+	case "import_statement", "export_statement":
+		spec := node.ChildByFieldName("source")
+		if spec == nil || hasAttributes(node) || isTypeOnly(node) {
+			break
+		}
+		if typ, ok := attributeType(spec, source, types); ok {
+			quote := source[spec.StartByte()]
+			return newInsertion(spec, statementKind(node), attributeClause(keyword, typ, quote)), true
+		}
+
+	case "call_expression":
+		fn := node.ChildByFieldName("function")
+		args := node.ChildByFieldName("arguments")
+		if fn == nil || fn.Kind() != "import" || args == nil || args.NamedChildCount() != 1 {
+			break
+		}
+		// Import types only accept a resolution-mode attribute.
+		if importKind(node) == ImportType {
+			break
+		}
+		spec := args.NamedChild(0)
+		if spec.Kind() != "string" {
+			break
+		}
+		if typ, ok := attributeType(spec, source, types); ok {
+			quote := source[spec.StartByte()]
+			return newInsertion(spec, DynamicImport, attributeOptions(keyword, typ, quote)), true
+		}
+	}
+	return Finding{}, false
+}
+
+// newInsertion records a MissingAttributes finding that inserts text
+// right after spec.
+func newInsertion(spec *tree_sitter.Node, kind EditKind, text string) Finding {
+	end := spec.EndPosition()
+	p := Point{Row: end.Row, Column: end.Column}
+	return Finding{
+		StartByte:  spec.EndByte(),
+		EndByte:    spec.EndByte(),
+		StartPoint: p,
+		EndPoint:   p,
+		Kind:       kind,
+		Strategy:   MissingAttributes,
+		Insertion:  text,
+	}
+}
+
+// hasAttributes returns true if the statement already carries an
+// attribute or assertion clause, including one the grammar only
+// recovered as an ERROR node.
+func hasAttributes(stmt *tree_sitter.Node) bool {
+	for i := uint(0); i < stmt.ChildCount(); i++ {
+		kind := stmt.Child(i).Kind()
+		if isImportAttributeNode(kind) || kind == "ERROR" {
+			return true
+		}
+	}
+	return false
+}
+
+// isTypeOnly returns true for `import type ...` and `export type ...`
+// statements.
+func isTypeOnly(stmt *tree_sitter.Node) bool {
+	for i := uint(0); i < stmt.ChildCount(); i++ {
+		child := stmt.Child(i)
+		if !child.IsNamed() && child.Kind() == "type" {
+			return true
+		}
+	}
+	return false
+}
+
+// attributeType returns the type for the specifier string node, ignoring
+// any query string or fragment.
+func attributeType(spec *tree_sitter.Node, source []byte, types map[string]string) (string, bool) {
+	text := nodeText(spec, source)
+	if len(text) < 2 {
+		return "", false
+	}
+	specifier := text[1 : len(text)-1]
+	if i := strings.IndexAny(specifier, "?#"); i >= 0 {
+		specifier = specifier[:i]
+	}
+	typ, ok := types[path.Ext(specifier)]
+	return typ, ok
+}
@@ -0,0 +1,156 @@
+package transform
+
+import "testing"
+
+func TestAddImportAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		lang  Language
+		types map[string]string
+		count int
+	}{
+		{
+			name:  "default import",
+			input: `import pkg from './package.json';`,
+			want:  `import pkg from './package.json' with { type: 'json' };`,
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "double quotes and no semicolon",
+			input: "import pkg from \"./package.json\"\nconsole.log(pkg)\n",
+			want:  "import pkg from \"./package.json\" with { type: \"json\" }\nconsole.log(pkg)\n",
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "re-exports",
+			input: "export { version } from './package.json';\nexport * from './data.json';\n",
+			want:  "export { version } from './package.json' with { type: 'json' };\nexport * from './data.json' with { type: 'json' };\n",
+			lang:  TypeScript,
+			count: 2,
+		},
+		{
+			name:  "dynamic import",
+			input: `const data = await import('./data.json');`,
+			want:  `const data = await import('./data.json', { with: { type: 'json' } });`,
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "import type",
+			input: "type Data = typeof import('./data.json');\nlet x: import('./data.json').default;\n",
+			want:  "type Data = typeof import('./data.json');\nlet x: import('./data.json').default;\n",
+			lang:  TypeScript,
+			count: 0,
+		},
+		{
+			name:  "query string",
+			input: `import data from './data.json?v=2';`,
+			want:  `import data from './data.json?v=2' with { type: 'json' };`,
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "already has attributes",
+			input: "import a from './a.json' with { type: 'json' };\nconst b = await import('./b.json', { with: { type: 'json' } });\n",
+			want:  "import a from './a.json' with { type: 'json' };\nconst b = await import('./b.json', { with: { type: 'json' } });\n",
+			lang:  TypeScript,
+			count: 0,
+		},
+		{
+			name:  "legacy assertion is not doubled",
+			input: `import a from './a.json' assert { type: 'json' };`,
+			want:  `import a from './a.json' assert { type: 'json' };`,
+			lang:  JavaScript,
+			count: 0,
+		},
+		{
+			name:  "type-only import",
+			input: `import type Pkg from './package.json';`,
+			want:  `import type Pkg from './package.json';`,
+			lang:  TypeScript,
+			count: 0,
+		},
+		{
+			name:  "other extensions untouched by default",
+			input: "import './styles.css';\nimport x from './x.js';\n",
+			want:  "import './styles.css';\nimport x from './x.js';\n",
+			lang:  JavaScript,
+			count: 0,
+		},
+		{
+			name:  "css when configured",
+			input: "import sheet from './styles.css';\nimport pkg from './package.json';\n",
+			want:  "import sheet from './styles.css' with { type: 'css' };\nimport pkg from './package.json' with { type: 'json' };\n",
+			lang:  JavaScript,
+			types: map[string]string{".json": "json", ".css": "css"},
+			count: 2,
+		},
+		{
+			name:  "template literal specifier is skipped",
+			input: "const d = await import(`./${name}.json`);",
+			want:  "const d = await import(`./${name}.json`);",
+			lang:  JavaScript,
+			count: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AddImportAttributes([]byte(tt.input), tt.lang, tt.types)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := string(result.Output); got != tt.want {
+				t.Errorf("output mismatch\ngot:  %s\nwant: %s", got, tt.want)
+			}
+			if result.Replacements != tt.count {
+				t.Errorf("replacements: got %d, want %d", result.Replacements, tt.count)
+			}
+			for _, e := range result.Edits {
+				if e.StartByte != e.EndByte || e.Original != "" {
+					t.Errorf("edit %+v is not an insertion", e)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_WithAddAttributes(t *testing.T) {
+	source := []byte("import a from './a.json' assert { type: 'json' };\nimport b from './b.json';\n")
+
+	m := NewMigrator(WithAddAttributes(nil))
+	defer m.Close()
+
+	result, err := m.MigrateAssertToWith(source, JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "import a from './a.json' with { type: 'json' };\nimport b from './b.json' with { type: 'json' };\n"
+	if got := string(result.Output); got != want {
+		t.Errorf("output mismatch\ngot:  %s\nwant: %s", got, want)
+	}
+	if len(result.Edits) != 2 || result.Edits[0].Original != "assert" || result.Edits[1].Original != "" {
+		t.Errorf("unexpected edits: %+v", result.Edits)
+	}
+
+	// The reverse migration inserts the keyword it writes.
+	result, err = m.MigrateWithToAssert([]byte("import b from './b.json';\n"), JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(result.Output), "import b from './b.json' assert { type: 'json' };\n"; got != want {
+		t.Errorf("reverse output mismatch\ngot:  %s\nwant: %s", got, want)
+	}
+
+	findings, err := m.Find(source, JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 2 || findings[1].Strategy != MissingAttributes || findings[1].Insertion != " with { type: 'json' }" {
+		t.Errorf("unexpected findings: %+v", findings)
+	}
+}
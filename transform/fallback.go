@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"bytes"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// fallbackOrder is the order in which WithGrammarFallback tries the
+// grammars other than the one requested. TypeScript and TSX sources skip
+// JavaScript, which cannot parse their type annotations.
+var fallbackOrder = []Language{JavaScript, TSX, TypeScript}
+
+// parsed is the tree of a source and its parse errors.
+type parsed struct {
+	tree *tree_sitter.Tree
+	// lang is the grammar the tree was parsed with.
+	lang Language
+	errs []ParseError
+}
+
+// parseSource parses source with lang. With WithGrammarFallback, if the
+// tree has a parse error near an import or export, one that the
+// attribute clauses do not explain, the other grammars in fallbackOrder
+// are tried and the first without one is used; if none manages, the
+// tree for lang is kept. The caller must close the tree.
+func (m *Migrator) parseSource(source []byte, lang Language) (*parsed, error) {
+	first, err := m.parseLanguage(source, lang)
+	if err != nil || !m.fallback || !errorsNearImports(source, first.errs) {
+		return first, err
+	}
+
+	for _, alt := range fallbackOrder {
+		if alt == lang || (alt == JavaScript && lang != JavaScript) {
+			continue
+		}
+		p, err := m.parseLanguage(source, alt)
+		if err != nil {
+			first.tree.Close()
+			return nil, err
+		}
+		if !errorsNearImports(source, p.errs) {
+			first.tree.Close()
+			return p, nil
+		}
+		p.tree.Close()
+	}
+	return first, nil
+}
+
+// parseLanguage parses source with lang and finds its parse errors.
+func (m *Migrator) parseLanguage(source []byte, lang Language) (*parsed, error) {
+	tree, err := m.parse(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	errs, err := m.parseErrors(tree.RootNode(), source, lang)
+	if err != nil {
+		tree.Close()
+		return nil, err
+	}
+	return &parsed{tree: tree, lang: lang, errs: errs}, nil
+}
+
+// errorsNearImports reports whether any error in errs that is not in an
+// attribute clause is on a line that mentions import or export. Error
+// recovery often swallows the statement around the error, so this looks
+// at the text rather than the tree.
+func errorsNearImports(source []byte, errs []ParseError) bool {
+	for _, e := range errs {
+		if e.Attribute {
+			continue
+		}
+		start := bytes.LastIndexByte(source[:e.StartByte], '\n') + 1
+		end := len(source)
+		if i := bytes.IndexByte(source[e.EndByte:], '\n'); i >= 0 {
+			end = int(e.EndByte) + i
+		}
+		lines := source[start:end]
+		if indexWord(lines, "import", 0) >= 0 || indexWord(lines, "export", 0) >= 0 {
+			return true
+		}
+	}
+	return false
+}
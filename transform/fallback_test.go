@@ -0,0 +1,77 @@
+package transform
+
+import "testing"
+
+func TestWithGrammarFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		lang   Language
+		// want is the grammar used with the fallback enabled.
+		want Language
+	}{
+		{
+			name:   "type import in a .js file",
+			source: "import type { T } from './t';\nimport a from './a.json' assert { type: 'json' };\n",
+			lang:   JavaScript,
+			want:   TSX,
+		},
+		{
+			name:   "JSX in a .ts file",
+			source: "import a from './a.json' assert { type: 'json' };\nexport const el = <div className=\"x\">hi</div>;\n",
+			lang:   TypeScript,
+			want:   TSX,
+		},
+		{
+			// The JavaScript grammar's error is in the attribute clause,
+			// which the matcher recovers, so it is kept.
+			name:   "assert clause in the JavaScript grammar",
+			source: "import a from './a.json' assert { type: 'json' };\n",
+			lang:   JavaScript,
+			want:   JavaScript,
+		},
+		{
+			name:   "errors away from imports",
+			source: "import a from './a.json' assert { type: 'json' };\nfoo(\n",
+			lang:   TypeScript,
+			want:   TypeScript,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := []byte(tt.source)
+
+			off := NewMigrator()
+			defer off.Close()
+			result, err := off.MigrateAssertToWith(source, tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Language != tt.lang {
+				t.Errorf("without fallback: Language = %s, want %s", result.Language, tt.lang)
+			}
+
+			on := NewMigrator(WithGrammarFallback(true))
+			defer on.Close()
+			result, err = on.MigrateAssertToWith(source, tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Language != tt.want {
+				t.Errorf("Language = %s, want %s", result.Language, tt.want)
+			}
+			if result.Replacements != 1 {
+				t.Errorf("Replacements = %d, want 1", result.Replacements)
+			}
+
+			scan, err := on.Scan(source, tt.lang, AssertToWith)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if scan.Language != tt.want || len(scan.Findings) != 1 {
+				t.Errorf("Scan = %+v, want one finding with %s", scan, tt.want)
+			}
+		})
+	}
+}
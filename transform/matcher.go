@@ -0,0 +1,238 @@
+package transform
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// matcherPatterns select the nodes that matchKeyword or isImportOptions
+// can accept, so only those are examined instead of every node in the
+// tree. Each capture name says which check applies:
+//
+//   - @attribute: a keyword token inside an attribute clause (strategy 1)
+//   - @error: any ERROR node (strategies 2a and 2b)
+//   - @property: an identifier or quoted key spelling a keyword at most
+//     six levels below an import() call or import type, the furthest
+//     enclosingDynamicImport looks (strategy 3)
+//   - @options: an object passed to import() (see StripRule)
+//
+// Queries cannot say "nearest enclosing call", so @property is a
+// superset that matchKeyword narrows down; it is still far smaller than
+// the set of all identifiers.
+//
+// A grammar that lacks one of the node types rejects the whole query, so
+// patterns are compiled one by one and only those valid for the
+// Language are kept.
+var matcherPatterns = func() []string {
+	var patterns []string
+	for _, attr := range []string{"import_attribute", "import_assertion", "assert_clause"} {
+		for _, kw := range []string{"assert", "with"} {
+			patterns = append(patterns, `(`+attr+` "`+kw+`" @attribute)`)
+		}
+	}
+	patterns = append(patterns, `(ERROR) @error`)
+	for _, kind := range []string{"property_identifier", "shorthand_property_identifier", "identifier", "string_fragment"} {
+		inner := `(` + kind + `) @property`
+		for depth := 1; depth <= 6; depth++ {
+			patterns = append(patterns,
+				`(call_expression function: (import) `+inner+` (#any-of? @property "assert" "with"))`,
+				`(import_type `+inner+` (#any-of? @property "assert" "with"))`)
+			inner = `(_ ` + inner + `)`
+		}
+	}
+	patterns = append(patterns,
+		`(call_expression function: (import) arguments: (arguments (object) @options))`)
+	return patterns
+}()
+
+// matcher is the compiled matcher query for one Language.
+type matcher struct {
+	query *tree_sitter.Query
+	// options is the index of the @options capture.
+	options uint
+}
+
+var (
+	matchersMu sync.Mutex
+	// matchers caches the compiled query per Language. Queries are only
+	// read after compilation, so one query is shared by all goroutines;
+	// each search uses its own cursor.
+	matchers = make(map[Language]*matcher)
+)
+
+// matcherFor returns the compiled matcher for lang, compiling it on
+// first use.
+func matcherFor(lang Language) (*matcher, error) {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+
+	if m, ok := matchers[lang]; ok {
+		return m, nil
+	}
+
+	ptr, err := getLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	language := tree_sitter.NewLanguage(ptr)
+
+	var valid []string
+	for _, p := range matcherPatterns {
+		q, qerr := tree_sitter.NewQuery(language, p)
+		if qerr != nil {
+			continue
+		}
+		q.Close()
+		valid = append(valid, p)
+	}
+	q, qerr := tree_sitter.NewQuery(language, strings.Join(valid, "\n"))
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	m := &matcher{query: q}
+	m.options, _ = q.CaptureIndexForName("options")
+	matchers[lang] = m
+	return m, nil
+}
+
+// candidate is a node captured by the matcher query.
+type candidate struct {
+	node    tree_sitter.Node
+	options bool
+}
+
+// candidates runs the matcher query for lang over root and returns the
+// captured nodes in the order a pre-order walk would visit them.
+//
+// Every node that can match spells one of keywords, or contains a node
+// that does, so the query only runs over the byte range of each
+// occurrence of a keyword. The cursor then skips whole subtrees that do
+// not contain one, which on a large file is most of the tree.
+func candidates(root *tree_sitter.Node, lang Language, source []byte, keywords ...string) ([]candidate, error) {
+	m, err := matcherFor(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	var out []candidate
+	seen := make(map[uintptr]bool)
+	for _, kw := range keywords {
+		for i := indexWord(source, kw, 0); i >= 0; i = indexWord(source, kw, i+len(kw)) {
+			cursor.SetByteRange(uint(i), uint(i+len(kw)))
+			matches := cursor.Matches(m.query, root, source)
+			for match := matches.Next(); match != nil; match = matches.Next() {
+				for _, c := range match.Captures {
+					// Containers span several occurrences and are found
+					// once for each.
+					if id := c.Node.Id(); !seen[id] {
+						seen[id] = true
+						out = append(out, candidate{node: c.Node, options: uint(c.Index) == m.options})
+					}
+				}
+			}
+		}
+	}
+
+	// Parents come before their children: ties on the start offset go to
+	// the longer node, then to the node that can contain others.
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := &out[i].node, &out[j].node
+		if a.StartByte() != b.StartByte() {
+			return a.StartByte() < b.StartByte()
+		}
+		if a.EndByte() != b.EndByte() {
+			return a.EndByte() > b.EndByte()
+		}
+		return isContainer(&out[i]) && !isContainer(&out[j])
+	})
+	return out, nil
+}
+
+// isContainer reports whether c may have other candidates as descendants.
+func isContainer(c *candidate) bool {
+	return c.options || c.node.Kind() == "ERROR"
+}
+
+// collectComputedKeys finds the computed keys in import() options
+// objects in root, in source order. Only their calls are searched, so the
+// query runs over each occurrence of the word import. The key may be
+// defined anywhere, even in another module, so it is reported whatever
+// keywords the source spells.
+func collectComputedKeys(root *tree_sitter.Node, lang Language, source []byte, out *[]Finding) error {
+	cands, err := candidates(root, lang, source, "import")
+	if err != nil {
+		return err
+	}
+
+	for i := range cands {
+		c := &cands[i]
+		if !c.options || !isImportOptions(&c.node) {
+			continue
+		}
+		kind := importKind(c.node.Parent().Parent())
+		for j := uint(0); j < c.node.NamedChildCount(); j++ {
+			pair := c.node.NamedChild(j)
+			if pair.Kind() != "pair" {
+				continue
+			}
+			// A string literal is matched as a quoted key instead.
+			key := pair.ChildByFieldName("key")
+			if !isComputedKey(key) || quotedKeyText(key) != nil {
+				continue
+			}
+			*out = append(*out, newFinding(key, source, kind, ComputedKey))
+		}
+	}
+	return nil
+}
+
+// collectMigrations is collectFindings for the keyword dir rewrites.
+// WithToAssert leaves TypeScript import types alone: they never reach
+// the runtime, and TypeScript 5.3 moved their options to `with`.
+func collectMigrations(root *tree_sitter.Node, lang Language, source []byte, dir Direction, out *[]Finding) error {
+	n := len(*out)
+	if err := collectFindings(root, lang, source, dir.From(), out); err != nil {
+		return err
+	}
+	if dir != WithToAssert {
+		return nil
+	}
+	kept := (*out)[:n]
+	for _, f := range (*out)[n:] {
+		if f.Kind != ImportType {
+			kept = append(kept, f)
+		}
+	}
+	*out = kept
+	return nil
+}
+
+// collectFindings finds all tokens spelling keyword ("assert" or "with")
+// that appear in import/export attribute positions, in source order.
+// As in a tree walk, nothing inside a node that matched is considered.
+func collectFindings(root *tree_sitter.Node, lang Language, source []byte, keyword string, out *[]Finding) error {
+	cands, err := candidates(root, lang, source, keyword)
+	if err != nil {
+		return err
+	}
+
+	var skip uint
+	for i := range cands {
+		c := &cands[i]
+		if c.options || c.node.StartByte() < skip {
+			continue
+		}
+		if f, _, ok := matchKeyword(&c.node, source, keyword); ok {
+			*out = append(*out, f)
+			skip = c.node.EndByte()
+		}
+	}
+	return nil
+}
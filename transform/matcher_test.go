@@ -0,0 +1,186 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// walkFindings is the recursive tree walk that collectFindings replaced.
+// It visits every node and is kept as the reference the query-based
+// matcher must agree with.
+func walkFindings(node *tree_sitter.Node, source []byte, keyword string, out *[]Finding) {
+	if node == nil {
+		return
+	}
+
+	if f, _, ok := matchKeyword(node, source, keyword); ok {
+		*out = append(*out, f)
+		return
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		walkFindings(node.Child(i), source, keyword, out)
+	}
+}
+
+// matcherCorpus covers every strategy in each grammar, plus the
+// identifiers and ERROR nodes that must not match.
+var matcherCorpus = []string{
+	`import data from './data.json' assert { type: 'json' };`,
+	`import data from './data.json' with { type: 'json' };`,
+	`import { a, b } from './data.json' assert { type: 'json' };`,
+	`export { default } from './data.json' assert { type: 'json' };`,
+	`export * from './data.json' with { type: 'json' };`,
+	`export * as ns from './data.json' assert { type: 'json' }; export * as other from './other.json' with { type: 'json' };`,
+	`export { x } from './x.json' assert { type: 'json' }; export { y } from './y.json' with { type: 'json' };`,
+	`const a = await import('./a.json', { assert: { type: 'json' } });`,
+	`const a = await import('./a.json', { with: { type: 'json' } });`,
+	`const a = await import('./a.json', { with: { type: 'json' }, signal });`,
+	`const a = await import('./a.json', { assert, with });`,
+	`const a = import(assert);`,
+	`import('./a.json', { nested: { with: { with: 'json' } } });`,
+	`console.assert(x); const with_ = 1; function assert() {} obj.with = 2;`,
+	`const o = { assert: true, with: false };`,
+	`with (obj) { assert(x); }`,
+	`import a from './a.json' assert { type: 'json' }
+import b from './b.json' with { type: 'json' }
+const c = await import('./c.json', { assert: { type: 'json' } })
+export { d } from './d.json' assert { type: 'json' }`,
+	`import type { T } from './t.js'; import x from './x.json' assert { type: 'json' }; let y: Assert<with> = 1;`,
+	`const el = <div assert="x" with={y} />; import z from './z.json' with { type: 'json' };`,
+	`type A = import('pkg', { assert: { 'resolution-mode': 'require' } }).A; let b: typeof import('pkg', { with: { 'resolution-mode': 'import' } });`,
+	`import('./a.json', { 'assert': { type: 'json' } }); import('./b.json', { ["with"]: { 'with': 'json' } }); import('assert'); import('./c.json', { [assert]: 1, type: 'with' });`,
+	`import broken from './b.json' assert { type: 'json' ;; export {`,
+}
+
+func TestCollectFindings_MatchesWalk(t *testing.T) {
+	sample, err := os.ReadFile("../testdata/sample.ts")
+	if err != nil {
+		t.Fatalf("reading sample: %v", err)
+	}
+	corpus := append([]string{string(sample)}, matcherCorpus...)
+
+	m := NewMigrator()
+	defer m.Close()
+
+	for _, lang := range []Language{JavaScript, TypeScript, TSX} {
+		for _, keyword := range []string{"assert", "with"} {
+			for i, src := range corpus {
+				source := []byte(src)
+				tree, err := m.parse(source, lang)
+				if err != nil {
+					t.Fatalf("parse: %v", err)
+				}
+
+				var want, got []Finding
+				walkFindings(tree.RootNode(), source, keyword, &want)
+				if err := collectFindings(tree.RootNode(), lang, source, keyword, &got); err != nil {
+					t.Fatalf("collectFindings: %v", err)
+				}
+				tree.Close()
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s/%s/corpus[%d]: query matcher disagrees with tree walk\ngot:  %+v\nwant: %+v",
+						lang, keyword, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMatcherFor_Cached(t *testing.T) {
+	a, err := matcherFor(TypeScript)
+	if err != nil {
+		t.Fatalf("matcherFor: %v", err)
+	}
+	b, err := matcherFor(TypeScript)
+	if err != nil {
+		t.Fatalf("matcherFor: %v", err)
+	}
+	if a != b {
+		t.Error("matcher should be compiled once per language")
+	}
+	if _, err := matcherFor(Language(99)); err == nil {
+		t.Error("expected an error for an unsupported language")
+	}
+}
+
+// largeSource returns a large module in which, as in real code, import
+// attributes are rare: the sample's imports followed by many functions.
+func largeSource(b *testing.B) []byte {
+	sample, err := os.ReadFile("../testdata/sample.ts")
+	if err != nil {
+		b.Fatalf("reading sample: %v", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(sample)
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, `
+export async function handler%d(req: Request, opts = { retries: %d, verbose: false }) {
+	const { headers, body } = req;
+	if (headers.get('x-mode') === 'assert') {
+		console.assert(body != null, 'missing body');
+	}
+	const extra = %d %% 50 === 0 ? await import('./extra%d.json', { assert: { type: 'json' } }) : null;
+	return items.filter((item) => item.id > %d).map((item) => ({ ...item, extra, seen: true }));
+}
+`, i, i, i, i, i)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkCollectFindings compares the query-based matcher with the
+// tree walk it replaced on a large file. The tree is parsed once, so
+// only matching is measured.
+func BenchmarkCollectFindings(b *testing.B) {
+	source := largeSource(b)
+
+	m := NewMigrator()
+	defer m.Close()
+	tree, err := m.parse(source, TypeScript)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tree.Close()
+	root := tree.RootNode()
+
+	b.Run("walk", func(b *testing.B) {
+		b.SetBytes(int64(len(source)))
+		for i := 0; i < b.N; i++ {
+			var findings []Finding
+			walkFindings(root, source, "assert", &findings)
+		}
+	})
+
+	b.Run("query", func(b *testing.B) {
+		b.SetBytes(int64(len(source)))
+		for i := 0; i < b.N; i++ {
+			var findings []Finding
+			if err := collectFindings(root, TypeScript, source, "assert", &findings); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkFind_LargeFile measures a whole Find call, parse included, on
+// a large file.
+func BenchmarkFind_LargeFile(b *testing.B) {
+	source := largeSource(b)
+
+	m := NewMigrator()
+	defer m.Close()
+
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Find(source, TypeScript); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
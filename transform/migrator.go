@@ -0,0 +1,394 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// defaultMigrator backs the package-level functions, so that even
+// callers who never construct a Migrator reuse parsers between calls.
+var defaultMigrator = NewMigrator()
+
+// Migrator runs migrations using a pool of tree-sitter parsers, one
+// pool per Language. Creating a parser and loading a grammar into it
+// is comparatively expensive, so long-lived programs that migrate many
+// files should share a single Migrator.
+//
+// A Migrator is safe for concurrent use by multiple goroutines. Each
+// call borrows a parser for the duration of the parse and returns it
+// afterwards, so the pool grows to the peak number of concurrent calls.
+type Migrator struct {
+	mu     sync.Mutex
+	idle   map[Language][]*tree_sitter.Parser
+	closed bool
+
+	prefilter     bool
+	verify        bool
+	fallback      bool
+	minConfidence Confidence
+	// addTypes, if non-nil, makes Migrate and FindDirection also insert
+	// or report missing attributes; see WithAddAttributes.
+	addTypes map[string]string
+
+	parsed      atomic.Int64
+	prefiltered atomic.Int64
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithPrefilter enables or disables the byte-level prefilter. When
+// enabled (the default), sources that cannot contain the keyword being
+// migrated are returned unchanged without being parsed. The prefilter
+// never rejects a source that the parser-based matching would change.
+func WithPrefilter(enabled bool) Option {
+	return func(m *Migrator) {
+		m.prefilter = enabled
+	}
+}
+
+// WithVerify enables or disables verification of migrated output.
+// When enabled (the default), Migrate re-parses its output and returns a
+// *VerifyError instead of a result if the output has more syntax errors
+// than the source, or if a keyword it wrote is not in an import
+// attribute position. This guards against matches inside ERROR nodes
+// that the grammar could not make sense of.
+//
+// Run, which may apply any rules, only checks that its output has no more
+// syntax errors than the source outside attribute clauses (see
+// ParseError.Attribute).
+//
+// Output of WithToAssert in the JavaScript grammar is not verified,
+// since that grammar does not parse `assert` clauses at all.
+func WithVerify(enabled bool) Option {
+	return func(m *Migrator) {
+		m.verify = enabled
+	}
+}
+
+// WithGrammarFallback enables or disables retrying with other grammars.
+// When enabled, a source whose tree has parse errors near an import or
+// export is parsed again with the other grammars, in the order
+// JavaScript, TSX, TypeScript, and the first that parses those lines is
+// used; this covers .js files with TypeScript or Flow syntax and .ts
+// files with JSX. TypeScript and TSX sources never fall back to
+// JavaScript. Errors in attribute clauses the matcher recovers, such
+// as `assert` in the JavaScript grammar, do not count. Result.Language
+// records the grammar used. It is disabled by default.
+func WithGrammarFallback(enabled bool) Option {
+	return func(m *Migrator) {
+		m.fallback = enabled
+	}
+}
+
+// WithMinConfidence makes Run and Migrate apply only edits of at least
+// min confidence. The others are returned in Result.Held for manual
+// review. The default, LowConfidence, applies every edit.
+func WithMinConfidence(min Confidence) Option {
+	return func(m *Migrator) {
+		m.minConfidence = min
+	}
+}
+
+// WithAddAttributes makes Migrate and FindDirection also handle imports
+// that have no attributes at all, as AddImportAttributes does, inserting
+// the keyword the migration writes. types maps specifier extensions to
+// type values; nil means DefaultAttributeTypes.
+func WithAddAttributes(types map[string]string) Option {
+	return func(m *Migrator) {
+		if types == nil {
+			types = DefaultAttributeTypes
+		}
+		m.addTypes = types
+	}
+}
+
+// NewMigrator returns a Migrator with empty parser pools.
+func NewMigrator(opts ...Option) *Migrator {
+	m := &Migrator{
+		idle:      make(map[Language][]*tree_sitter.Parser),
+		prefilter: true,
+		verify:    true,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Stats counts the sources a Migrator has handled.
+type Stats struct {
+	// Parsed is the number of sources that were parsed.
+	Parsed int64
+	// Prefiltered is the number of sources the prefilter returned
+	// unchanged without parsing.
+	Prefiltered int64
+}
+
+// Stats returns the counts accumulated by the Migrate and Find methods
+// since the Migrator was created.
+func (m *Migrator) Stats() Stats {
+	return Stats{
+		Parsed:      m.parsed.Load(),
+		Prefiltered: m.prefiltered.Load(),
+	}
+}
+
+// Close releases all pooled parsers. The Migrator remains usable
+// afterwards, but parsers are no longer pooled.
+func (m *Migrator) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for lang, parsers := range m.idle {
+		for _, p := range parsers {
+			p.Close()
+		}
+		delete(m.idle, lang)
+	}
+	m.closed = true
+	return nil
+}
+
+// MigrateAssertToWith is like the package-level MigrateAssertToWith but
+// reuses pooled parsers.
+func (m *Migrator) MigrateAssertToWith(source []byte, lang Language) (*Result, error) {
+	return m.Migrate(source, lang, AssertToWith)
+}
+
+// MigrateWithToAssert is like the package-level MigrateWithToAssert but
+// reuses pooled parsers.
+func (m *Migrator) MigrateWithToAssert(source []byte, lang Language) (*Result, error) {
+	return m.Migrate(source, lang, WithToAssert)
+}
+
+// Migrate rewrites every dir.From() keyword in an import attribute
+// position to dir.To().
+//
+// With WithAddAttributes it also inserts dir.To() attribute clauses into
+// imports that have none. The output is verified unless WithVerify
+// disabled it.
+func (m *Migrator) Migrate(source []byte, lang Language, dir Direction) (*Result, error) {
+	rules := []Rule{MigrateRule(dir)}
+	if m.addTypes != nil {
+		rules = append(rules, AddAttributesRule(dir, m.addTypes))
+	}
+	if !m.verify {
+		return m.Run(source, lang, rules...)
+	}
+	return m.run(source, lang, rules, func(res *Result) error {
+		return m.verifyOutput(res.Language, dir, res)
+	})
+}
+
+// AddImportAttributes is like the package-level AddImportAttributes but
+// reuses pooled parsers.
+func (m *Migrator) AddImportAttributes(source []byte, lang Language, types map[string]string) (*Result, error) {
+	return m.Run(source, lang, AddAttributesRule(AssertToWith, types))
+}
+
+// Find is like the package-level Find but reuses pooled parsers.
+func (m *Migrator) Find(source []byte, lang Language) ([]Finding, error) {
+	return m.FindDirection(source, lang, AssertToWith)
+}
+
+// FindDirection reports every keyword that Migrate would rewrite for
+// dir, and with WithAddAttributes every missing attribute clause it
+// would insert, without rewriting anything.
+func (m *Migrator) FindDirection(source []byte, lang Language, dir Direction) ([]Finding, error) {
+	res, err := m.find(source, lang, dir, m.addTypes)
+	if err != nil {
+		return nil, err
+	}
+	return res.Findings, nil
+}
+
+// Scan is like FindDirection but also reports the parse errors in
+// source, the grammar it was parsed with and the computed import()
+// option keys it cannot check, as Migrate does.
+func (m *Migrator) Scan(source []byte, lang Language, dir Direction) (*ScanResult, error) {
+	return m.find(source, lang, dir, m.addTypes)
+}
+
+// find collects, in source order, the dir.From() keywords to rewrite
+// and, if types is non-nil, the dir.To() attribute clauses to insert.
+func (m *Migrator) find(source []byte, lang Language, dir Direction, types map[string]string) (*ScanResult, error) {
+	// As in run, an unsupported language is an error even when the
+	// source would be prefiltered.
+	if _, err := getLanguage(lang); err != nil {
+		return nil, err
+	}
+	if m.skip(func() bool {
+		return mayContainKeyword(source, dir.From()) || mayContainComputedKey(source) ||
+			mayNeedAttributes(source, types)
+	}) {
+		return &ScanResult{Language: lang, Prefiltered: true}, nil
+	}
+
+	p, err := m.parseSource(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer p.tree.Close()
+
+	res := &ScanResult{ParseErrors: p.errs, Language: p.lang}
+	if err := collectMigrations(p.tree.RootNode(), p.lang, source, dir, &res.Findings); err != nil {
+		return nil, err
+	}
+	if err := collectComputedKeys(p.tree.RootNode(), p.lang, source, &res.Unmigratable); err != nil {
+		return nil, err
+	}
+	if types != nil {
+		n := len(res.Findings)
+		collectMissingAttributes(p.tree.RootNode(), source, dir.To(), types, &res.Findings)
+		if n > 0 && len(res.Findings) > n {
+			sortFindings(res.Findings)
+		}
+	}
+	return res, nil
+}
+
+// FindUnsupported reports every import attribute keyword in source that
+// t does not accept, in source order. Unlike Migrate it looks for both
+// keywords, so it also covers targets that no migration can satisfy.
+// TypeScript import types are left out, as they never reach the runtime.
+func (m *Migrator) FindUnsupported(source []byte, lang Language, t Target) ([]Finding, error) {
+	support := t.Support()
+	var keywords []string
+	if !support.Assert {
+		keywords = append(keywords, "assert")
+	}
+	if !support.With {
+		keywords = append(keywords, "with")
+	}
+
+	// As in run, an unsupported language is an error even when the
+	// source would be prefiltered.
+	if _, err := getLanguage(lang); err != nil {
+		return nil, err
+	}
+	if m.skip(func() bool {
+		for _, kw := range keywords {
+			if mayContainKeyword(source, kw) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return nil, nil
+	}
+
+	p, err := m.parseSource(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer p.tree.Close()
+
+	var findings []Finding
+	for _, kw := range keywords {
+		if err := collectFindings(p.tree.RootNode(), p.lang, source, kw, &findings); err != nil {
+			return nil, err
+		}
+	}
+	runtime := findings[:0]
+	for _, f := range findings {
+		if f.Kind != ImportType {
+			runtime = append(runtime, f)
+		}
+	}
+	sortFindings(runtime)
+	return runtime, nil
+}
+
+// sortFindings orders findings collected by separate searches by
+// position.
+func sortFindings(findings []Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].StartByte < findings[j].StartByte
+	})
+}
+
+// DumpTree is like the package-level DumpTree but reuses pooled parsers.
+func (m *Migrator) DumpTree(source []byte, lang Language) (string, error) {
+	tree, err := m.parse(source, lang)
+	if err != nil {
+		return "", err
+	}
+	defer tree.Close()
+
+	return tree.RootNode().ToSexp(), nil
+}
+
+// skip reports whether the prefilter is enabled and possible returns
+// false, updating stats. Sources that pass are counted as parsed.
+func (m *Migrator) skip(possible func() bool) bool {
+	if m.prefilter && !possible() {
+		m.prefiltered.Add(1)
+		return true
+	}
+	m.parsed.Add(1)
+	return false
+}
+
+// parse parses source with a pooled parser for lang. The caller must
+// close the returned tree; the parser itself is returned to the pool.
+func (m *Migrator) parse(source []byte, lang Language) (*tree_sitter.Tree, error) {
+	parser, err := m.get(lang)
+	if err != nil {
+		return nil, err
+	}
+	defer m.put(lang, parser)
+
+	tree := parser.Parse(source, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("parse returned nil tree")
+	}
+	if tree.RootNode() == nil {
+		tree.Close()
+		return nil, fmt.Errorf("parse returned nil root node")
+	}
+	return tree, nil
+}
+
+// get borrows an idle parser for lang, creating one if the pool is empty.
+func (m *Migrator) get(lang Language) (*tree_sitter.Parser, error) {
+	m.mu.Lock()
+	if parsers := m.idle[lang]; len(parsers) > 0 {
+		p := parsers[len(parsers)-1]
+		m.idle[lang] = parsers[:len(parsers)-1]
+		m.mu.Unlock()
+		return p, nil
+	}
+	m.mu.Unlock()
+
+	tsLang, err := getLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := tree_sitter.NewParser()
+	if err := parser.SetLanguage(tree_sitter.NewLanguage(tsLang)); err != nil {
+		parser.Close()
+		return nil, fmt.Errorf("setting language: %w", err)
+	}
+	return parser, nil
+}
+
+// put returns a parser to the pool, or closes it if the Migrator has
+// been closed.
+func (m *Migrator) put(lang Language, parser *tree_sitter.Parser) {
+	parser.Reset()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		parser.Close()
+		return
+	}
+	m.idle[lang] = append(m.idle[lang], parser)
+}
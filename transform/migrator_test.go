@@ -0,0 +1,244 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMigrator_Concurrent(t *testing.T) {
+	inputs := []struct {
+		source string
+		lang   Language
+		want   string
+	}{
+		{
+			source: `import data from './data.json' assert { type: 'json' };`,
+			lang:   JavaScript,
+			want:   `import data from './data.json' with { type: 'json' };`,
+		},
+		{
+			source: `import data from './data.json' assert { type: 'json' };`,
+			lang:   TypeScript,
+			want:   `import data from './data.json' with { type: 'json' };`,
+		},
+		{
+			source: `const data = await import('./data.json', { assert: { type: 'json' } });`,
+			lang:   TSX,
+			want:   `const data = await import('./data.json', { with: { type: 'json' } });`,
+		},
+	}
+
+	m := NewMigrator()
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				in := inputs[(g+i)%len(inputs)]
+				result, err := m.MigrateAssertToWith([]byte(in.source), in.lang)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(result.Output) != in.want {
+					errs <- fmt.Errorf("got %q, want %q", result.Output, in.want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestMigrator_UsableAfterClose(t *testing.T) {
+	m := NewMigrator()
+	source := []byte(`import data from './data.json' assert { type: 'json' };`)
+
+	if _, err := m.Find(source, JavaScript); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	findings, err := m.Find(source, JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error after Close: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Errorf("finding count after Close: got %d, want 1", len(findings))
+	}
+}
+
+func TestMigrator_UnsupportedLanguage(t *testing.T) {
+	m := NewMigrator()
+	defer m.Close()
+
+	if _, err := m.MigrateAssertToWith([]byte(`x`), Language(99)); err == nil {
+		t.Error("expected an error for an unsupported language")
+	}
+	if _, err := m.Find([]byte(`x`), Language(99)); err == nil {
+		t.Error("Find: expected an error for an unsupported language")
+	}
+	target, _ := ParseTarget("node22")
+	if _, err := m.FindUnsupported([]byte(`x`), Language(99), target); err == nil {
+		t.Error("FindUnsupported: expected an error for an unsupported language")
+	}
+}
+
+func BenchmarkMigrateAssertToWith(b *testing.B) {
+	source := []byte(`import data from './data.json' assert { type: 'json' };
+const other = await import('./other.json', { assert: { type: 'json' } });
+`)
+
+	b.Run("pooled", func(b *testing.B) {
+		m := NewMigrator()
+		defer m.Close()
+		for i := 0; i < b.N; i++ {
+			if _, err := m.MigrateAssertToWith(source, JavaScript); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("fresh migrator per call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m := NewMigrator()
+			if _, err := m.MigrateAssertToWith(source, JavaScript); err != nil {
+				b.Fatal(err)
+			}
+			m.Close()
+		}
+	})
+}
+
+func TestWithMinConfidence(t *testing.T) {
+	// In the JavaScript grammar the static import is matched inside an
+	// ERROR node within the statement (medium), the re-export inside an
+	// ERROR node that replaced it (low), and import() options directly
+	// (high).
+	src := []byte(`import a from './a.json' assert { type: 'json' };
+export { b } from './b.json' assert { type: 'json' };
+const c = await import('./c.json', { assert: { type: 'json' } });
+`)
+
+	tests := []struct {
+		min         Confidence
+		applied     int
+		heldAtLines []uint
+	}{
+		{LowConfidence, 3, nil},
+		{MediumConfidence, 2, []uint{1}},
+		{HighConfidence, 1, []uint{0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.min.String(), func(t *testing.T) {
+			m := NewMigrator(WithMinConfidence(tt.min))
+			defer m.Close()
+
+			result, err := m.MigrateAssertToWith(src, JavaScript)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Replacements != tt.applied {
+				t.Errorf("Replacements = %d, want %d", result.Replacements, tt.applied)
+			}
+			var held []uint
+			for _, e := range result.Held {
+				if e.Confidence >= tt.min {
+					t.Errorf("held %s-confidence edit at line %d", e.Confidence, e.StartPoint.Row+1)
+				}
+				held = append(held, e.StartPoint.Row)
+			}
+			if fmt.Sprint(held) != fmt.Sprint(tt.heldAtLines) {
+				t.Errorf("held edits at rows %v, want %v", held, tt.heldAtLines)
+			}
+			if got := strings.Count(string(result.Output), "assert"); got != len(tt.heldAtLines) {
+				t.Errorf("output keeps %d assert(s), want %d:\n%s", got, len(tt.heldAtLines), result.Output)
+			}
+		})
+	}
+}
+
+func TestParseConfidence(t *testing.T) {
+	for _, c := range []Confidence{LowConfidence, MediumConfidence, HighConfidence} {
+		got, err := ParseConfidence(c.String())
+		if err != nil || got != c {
+			t.Errorf("ParseConfidence(%q) = %v, %v", c, got, err)
+		}
+	}
+	if _, err := ParseConfidence("certain"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestMigrator_Unmigratable(t *testing.T) {
+	source := []byte(`const a = await import('./a.json', { [key]: { type: 'json' } });
+const b = await import('./b.json', { assert: { type: 'json' } });
+type C = typeof import('pkg', { [mode]: { 'resolution-mode': 'import' } });
+const d = await import('./d.json', { ['assert']: { type: 'json' } });
+`)
+	m := NewMigrator()
+	defer m.Close()
+
+	result, err := m.Migrate(source, TypeScript, AssertToWith)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Replacements != 2 {
+		t.Errorf("replacements: got %d, want 2", result.Replacements)
+	}
+	scan, err := m.Scan(source, TypeScript, AssertToWith)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, got := range map[string][]Finding{"Migrate": result.Unmigratable, "Scan": scan.Unmigratable} {
+		if len(got) != 2 {
+			t.Fatalf("%s: got %d unmigratable keys, want 2: %+v", name, len(got), got)
+		}
+		if got[0].Text != "[key]" || got[0].Kind != DynamicImport || got[0].StartPoint.Row != 0 {
+			t.Errorf("%s: first key: got %+v", name, got[0])
+		}
+		if got[1].Text != "[mode]" || got[1].Kind != ImportType || got[1].Strategy != ComputedKey {
+			t.Errorf("%s: second key: got %+v", name, got[1])
+		}
+	}
+}
+
+// TestMigrator_UnmigratableImportedKey checks that a key defined in
+// another module is reported although the source never spells assert.
+func TestMigrator_UnmigratableImportedKey(t *testing.T) {
+	source := []byte(`import { KEY } from './keys.js';
+const a = await import('./a.json', { [KEY]: { type: 'json' } });
+`)
+	for _, prefilter := range []bool{true, false} {
+		m := NewMigrator(WithPrefilter(prefilter))
+		result, err := m.MigrateAssertToWith(source, JavaScript)
+		if err != nil {
+			t.Fatalf("prefilter %v: unexpected error: %v", prefilter, err)
+		}
+		scan, err := m.Scan(source, JavaScript, AssertToWith)
+		if err != nil {
+			t.Fatalf("prefilter %v: unexpected error: %v", prefilter, err)
+		}
+		for name, got := range map[string][]Finding{"Migrate": result.Unmigratable, "Scan": scan.Unmigratable} {
+			if len(got) != 1 || got[0].Text != "[KEY]" || got[0].StartPoint.Row != 1 {
+				t.Errorf("prefilter %v: %s: got %+v, want [KEY] on line 2", prefilter, name, got)
+			}
+		}
+		m.Close()
+	}
+}
@@ -0,0 +1,69 @@
+package transform
+
+import "bytes"
+
+// mayContainKeyword is a cheap byte-level check run before parsing.
+// Every match made by collectFindings needs the keyword (`assert` or
+// `with`) as a standalone word, plus an `import` (static or dynamic) or
+// an `export` (re-export) somewhere in the file. Sources failing either
+// test cannot produce a finding, so they never need to be parsed.
+//
+// The check is deliberately conservative: it may let through sources
+// with no assertions (e.g. `console.assert(...)` in a module), but it
+// never rejects one that has them.
+func mayContainKeyword(source []byte, keyword string) bool {
+	if !containsWord(source, keyword) {
+		return false
+	}
+	return containsWord(source, "import") || containsWord(source, "export")
+}
+
+// mayContainComputedKey is the prefilter check for collectComputedKeys.
+// A computed key in import() options needs the word import followed by
+// `(` (or a comment) and a `[` somewhere after it.
+func mayContainComputedKey(source []byte) bool {
+	for i := indexWord(source, "import", 0); i >= 0; i = indexWord(source, "import", i+1) {
+		j := skipSpace(source, uint(i+len("import")))
+		if j < uint(len(source)) && (source[j] == '(' || source[j] == '/') {
+			// Any later call has a suffix of this one's text to search.
+			return bytes.IndexByte(source[j:], '[') >= 0
+		}
+	}
+	return false
+}
+
+// containsWord reports whether word occurs in source with no identifier
+// character immediately before or after it, so that e.g. `assertEqual`
+// or `reimport` do not count.
+func containsWord(source []byte, word string) bool {
+	return indexWord(source, word, 0) >= 0
+}
+
+// indexWord returns the offset of the first occurrence of word at or
+// after from that containsWord would count, or -1 if there is none.
+func indexWord(source []byte, word string, from int) int {
+	w := []byte(word)
+	for offset := from; offset <= len(source); {
+		i := bytes.Index(source[offset:], w)
+		if i < 0 {
+			return -1
+		}
+		start := offset + i
+		end := start + len(w)
+		if (start == 0 || !isIdentByte(source[start-1])) &&
+			(end == len(source) || !isIdentByte(source[end])) {
+			return start
+		}
+		offset = start + 1
+	}
+	return -1
+}
+
+// isIdentByte reports whether b is an ASCII identifier character.
+// Non-ASCII bytes are treated as separators (they may be Unicode
+// whitespace), which errs on the side of parsing.
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') ||
+		('0' <= b && b <= '9')
+}
@@ -0,0 +1,94 @@
+package transform
+
+import "testing"
+
+func TestMayContainKeyword(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"static import", `import a from './a.json' assert { type: 'json' };`, true},
+		{"re-export", `export { a } from './a.json' assert { type: 'json' };`, true},
+		{"dynamic import", `await import('./a.json', { assert: { type: 'json' } });`, true},
+		{"quoted key", `await import('./a.json', { 'assert': { type: 'json' } });`, true},
+		{"no assert", `import a from './a.json' with { type: 'json' };`, false},
+		{"assert without import or export", `console.assert(true);`, false},
+		{"assert as identifier prefix", `import { assertEqual } from 'node:assert/strict';`, true},
+		{"only longer identifiers", `import { assertEqual } from 'x'; assertEqual(1, 1);`, false},
+		{"import only as identifier suffix", `reimport(); console.assert(1);`, false},
+		{"empty", ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mayContainKeyword([]byte(tt.input), "assert"); got != tt.want {
+				t.Errorf("mayContainKeyword(%q, \"assert\") = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMayContainComputedKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"computed key", `import('./a.json', { [KEY]: { type: 'json' } });`, true},
+		{"space and comment before the call", "import /* lazy */ ('./a.json', {\n  [KEY]: {},\n});", true},
+		{"import type", `type A = typeof import('pkg', { [mode]: {} });`, true},
+		{"bracket before the call only", `const a = [1]; import('./a.js');`, false},
+		{"static import", `import { a } from './a.js'; const b = a[0];`, false},
+		{"import only as identifier suffix", `reimport([1]);`, false},
+		{"empty", ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mayContainComputedKey([]byte(tt.input)); got != tt.want {
+				t.Errorf("mayContainComputedKey(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrator_PrefilterStats(t *testing.T) {
+	sources := [][]byte{
+		[]byte(`import data from './data.json' assert { type: 'json' };`),
+		[]byte(`import React from 'react';`),
+		[]byte(`export const x = 1;`),
+	}
+
+	m := NewMigrator()
+	defer m.Close()
+	for _, src := range sources {
+		result, err := m.MigrateAssertToWith(src, JavaScript)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(result.Output) != string(src) && result.Prefiltered {
+			t.Errorf("prefiltered result must equal input, got %q", result.Output)
+		}
+	}
+
+	if got, want := m.Stats(), (Stats{Parsed: 1, Prefiltered: 2}); got != want {
+		t.Errorf("stats: got %+v, want %+v", got, want)
+	}
+
+	noFilter := NewMigrator(WithPrefilter(false))
+	defer noFilter.Close()
+	for _, src := range sources {
+		result, err := noFilter.MigrateAssertToWith(src, JavaScript)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Prefiltered {
+			t.Errorf("result marked prefiltered with the prefilter disabled")
+		}
+	}
+
+	if got, want := noFilter.Stats(), (Stats{Parsed: 3}); got != want {
+		t.Errorf("stats without prefilter: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// QuerySpec describes a rule written as a tree-sitter query instead of
+// Go code. For every match of Query, the node captured as Capture is
+// replaced with Template, in which `{{name}}` stands for the text of the
+// node captured as @name:
+//
+//	Query:    `(import_statement source: (string (string_fragment) @pkg) (#match? @pkg "^lodash/"))`
+//	Capture:  "pkg"
+//	Template: "{{pkg}}.js"
+//
+// Query predicates such as #eq? and #match? are honoured. An empty
+// Template deletes the captured node.
+type QuerySpec struct {
+	// Name identifies the rule; see Rule.Name.
+	Name string
+	// Query is a tree-sitter query in S-expression syntax.
+	Query string
+	// Capture names the capture to replace, without the leading @.
+	Capture string
+	// Template is the replacement text.
+	Template string
+	// Languages restricts the rule to these grammars; nil means all of
+	// them. The query must compile for each one, since node types differ
+	// between the JavaScript and TypeScript grammars.
+	Languages []Language
+}
+
+// QueryRule is a Rule built from a QuerySpec. Its queries are compiled
+// once and shared, so a QueryRule is safe for concurrent use. Call Close
+// to release them.
+type QueryRule struct {
+	spec     QuerySpec
+	queries  map[Language]*tree_sitter.Query
+	template []templatePart
+}
+
+// templatePart is a literal run of template text, or a capture reference
+// when capture is set.
+type templatePart struct {
+	text    string
+	capture string
+}
+
+// NewQueryRule compiles spec for each of its languages. It fails if the
+// query does not compile, if it has no capture named spec.Capture, or if
+// the template references an unknown capture.
+func NewQueryRule(spec QuerySpec) (*QueryRule, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("query rule has no name")
+	}
+	langs := spec.Languages
+	if langs == nil {
+		langs = []Language{JavaScript, TypeScript, TSX}
+	}
+
+	r := &QueryRule{
+		spec:     spec,
+		queries:  make(map[Language]*tree_sitter.Query, len(langs)),
+		template: parseTemplate(spec.Template),
+	}
+	for _, lang := range langs {
+		ptr, err := getLanguage(lang)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("rule %s: %w", spec.Name, err)
+		}
+		q, qerr := tree_sitter.NewQuery(tree_sitter.NewLanguage(ptr), spec.Query)
+		if qerr != nil {
+			r.Close()
+			return nil, fmt.Errorf("rule %s: %s query: %w", spec.Name, lang, qerr)
+		}
+		r.queries[lang] = q
+
+		if _, ok := q.CaptureIndexForName(spec.Capture); !ok {
+			r.Close()
+			return nil, fmt.Errorf("rule %s: query has no capture @%s", spec.Name, spec.Capture)
+		}
+		for _, p := range r.template {
+			if _, ok := q.CaptureIndexForName(p.capture); p.capture != "" && !ok {
+				r.Close()
+				return nil, fmt.Errorf("rule %s: template references unknown capture @%s", spec.Name, p.capture)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Name returns spec.Name.
+func (r *QueryRule) Name() string { return r.spec.Name }
+
+// Visit runs the query over the whole tree when called with its root, so
+// it never needs to see the root's descendants. The kind of each edit is
+// that of the enclosing import statement, re-export or import() call, or
+// StaticImport if there is none.
+func (r *QueryRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	q, ok := r.queries[ctx.Language]
+	if !ok {
+		return false
+	}
+	target, _ := q.CaptureIndexForName(r.spec.Capture)
+	names := q.CaptureNames()
+
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	matches := cursor.Matches(q, node, ctx.Source)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		texts := make(map[string]string, len(m.Captures))
+		for _, c := range m.Captures {
+			if name := names[c.Index]; texts[name] == "" {
+				texts[name] = ctx.Text(&c.Node)
+			}
+		}
+		for _, c := range m.Captures {
+			if uint(c.Index) == target {
+				ctx.Replace(&c.Node, r.expand(texts), enclosingKind(&c.Node))
+			}
+		}
+	}
+	return false
+}
+
+// Close releases the compiled queries.
+func (r *QueryRule) Close() {
+	for _, q := range r.queries {
+		q.Close()
+	}
+}
+
+// expand fills in the template with the captured texts.
+func (r *QueryRule) expand(texts map[string]string) string {
+	var b strings.Builder
+	for _, p := range r.template {
+		if p.capture != "" {
+			b.WriteString(texts[p.capture])
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
+
+// parseTemplate splits a template into literal text and `{{name}}`
+// references. A `{{` without a matching `}}` is literal text.
+func parseTemplate(s string) []templatePart {
+	var parts []templatePart
+	for {
+		open := strings.Index(s, "{{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(s[open+2:], "}}")
+		if end < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[open+2 : open+2+end])
+		if open > 0 {
+			parts = append(parts, templatePart{text: s[:open]})
+		}
+		parts = append(parts, templatePart{capture: name})
+		s = s[open+2+end+2:]
+	}
+	if s != "" {
+		parts = append(parts, templatePart{text: s})
+	}
+	return parts
+}
+
+// enclosingKind returns the kind of the import construct containing
+// node, or StaticImport if there is none.
+func enclosingKind(node *tree_sitter.Node) EditKind {
+	for n := node; n != nil; n = n.Parent() {
+		switch n.Kind() {
+		case "import_statement":
+			return StaticImport
+		case "export_statement":
+			return ReExport
+		case "call_expression":
+			if fn := n.ChildByFieldName("function"); fn != nil && fn.Kind() == "import" {
+				return importKind(n)
+			}
+		case "import_type":
+			return ImportType
+		}
+	}
+	return StaticImport
+}
@@ -0,0 +1,149 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  QuerySpec
+		input string
+		want  string
+		lang  Language
+	}{
+		{
+			name: "rewrite specifier with predicate",
+			spec: QuerySpec{
+				Query:    `(import_statement source: (string (string_fragment) @path) (#match? @path "^~/"))`,
+				Capture:  "path",
+				Template: "./src/{{path}}",
+			},
+			input: "import a from '~/a.js';\nimport b from 'b';\n",
+			want:  "import a from './src/~/a.js';\nimport b from 'b';\n",
+			lang:  JavaScript,
+		},
+		{
+			name: "template uses other captures",
+			spec: QuerySpec{
+				Query:    `(import_statement (import_clause (identifier) @name) source: (string) @source) @stmt`,
+				Capture:  "stmt",
+				Template: "const {{name}} = require({{source}});",
+			},
+			input: "import a from './a.js';\n",
+			want:  "const a = require('./a.js');\n",
+			lang:  TypeScript,
+		},
+		{
+			name: "empty template deletes",
+			spec: QuerySpec{
+				Query:   `((comment) @c (#eq? @c "// @ts-ignore"))`,
+				Capture: "c",
+			},
+			input: "// @ts-ignore\nimport a from './a.json';\n// keep\n",
+			want:  "\nimport a from './a.json';\n// keep\n",
+			lang:  TSX,
+		},
+		{
+			name: "language not listed",
+			spec: QuerySpec{
+				Query:     `(string_fragment) @s`,
+				Capture:   "s",
+				Template:  "x",
+				Languages: []Language{TypeScript},
+			},
+			input: "import a from './a.js';\n",
+			want:  "import a from './a.js';\n",
+			lang:  JavaScript,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.Name = "test"
+			r, err := NewQueryRule(tt.spec)
+			if err != nil {
+				t.Fatalf("NewQueryRule: %v", err)
+			}
+			defer r.Close()
+
+			result, err := Run([]byte(tt.input), tt.lang, r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := string(result.Output); got != tt.want {
+				t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRule_WithMigration(t *testing.T) {
+	r, err := NewQueryRule(QuerySpec{
+		Name:     "move-json",
+		Query:    `(import_statement source: (string (string_fragment) @path) (#match? @path "\\.json$"))`,
+		Capture:  "path",
+		Template: "./data/{{path}}",
+	})
+	if err != nil {
+		t.Fatalf("NewQueryRule: %v", err)
+	}
+	defer r.Close()
+
+	source := []byte("import a from 'a.json' assert { type: 'json' };\n")
+	result, err := Run(source, JavaScript, MigrateRule(AssertToWith), r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "import a from './data/a.json' with { type: 'json' };\n"
+	if got := string(result.Output); got != want {
+		t.Errorf("output mismatch\ngot:  %s\nwant: %s", got, want)
+	}
+	if len(result.Edits) != 2 || result.Edits[0].Rule != "move-json" || result.Edits[0].Kind != StaticImport {
+		t.Errorf("unexpected edits: %+v", result.Edits)
+	}
+}
+
+func TestNewQueryRule_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec QuerySpec
+		want string
+	}{
+		{
+			name: "no name",
+			spec: QuerySpec{Query: `(string) @s`, Capture: "s"},
+			want: "no name",
+		},
+		{
+			name: "syntax error",
+			spec: QuerySpec{Name: "r", Query: `(string @s`, Capture: "s"},
+			want: "javascript query",
+		},
+		{
+			name: "node type missing from a grammar",
+			spec: QuerySpec{Name: "r", Query: `(type_annotation) @t`, Capture: "t"},
+			want: "javascript query",
+		},
+		{
+			name: "unknown capture",
+			spec: QuerySpec{Name: "r", Query: `(string) @s`, Capture: "source"},
+			want: "no capture @source",
+		},
+		{
+			name: "unknown template capture",
+			spec: QuerySpec{Name: "r", Query: `(string) @s`, Capture: "s", Template: "{{ t }}"},
+			want: "unknown capture @t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewQueryRule(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Rule is a codemod over the concrete syntax tree. Migrator.Run parses a
+// source once and walks the tree, calling Visit for every node, parents
+// before children, for each rule in turn.
+//
+// The built-in migrations are themselves rules (see MigrateRule,
+// AddAttributesRule and StripRule), so custom rules can be combined with
+// them in a single run.
+type Rule interface {
+	// Name identifies the rule in the registry and in Edit.Rule.
+	Name() string
+	// Visit inspects node and records edits through ctx. It returns
+	// false to skip the node's descendants for this rule.
+	Visit(ctx *Context, node *tree_sitter.Node) bool
+}
+
+// PrefilterRule is implemented by rules that can cheaply rule out a
+// source without parsing it. If the Migrator's prefilter is enabled and
+// every rule in a run implements PrefilterRule and reports false, the
+// source is returned unchanged without being parsed.
+type PrefilterRule interface {
+	Rule
+	// MayMatch reports whether the rule could edit source. It must never
+	// return false for a source the rule would edit.
+	MayMatch(source []byte) bool
+}
+
+// Context is passed to Rule.Visit. It exposes the source being migrated
+// and collects the rule's edits.
+type Context struct {
+	// Source is the original source. Rules must not modify it.
+	Source []byte
+	// Language is the grammar the source was parsed with.
+	Language Language
+
+	rule  string
+	lines *lineIndex
+	edits []Edit
+	// unmigratable collects MigrateRule's ComputedKey findings.
+	unmigratable []Finding
+	// err is the first error a built-in rule hit, such as a matcher
+	// query that failed to compile. Run returns it instead of a result.
+	err error
+}
+
+// fail records err, if it is the first, for Run to return.
+func (c *Context) fail(err error) {
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// Text returns the source text of node.
+func (c *Context) Text(node *tree_sitter.Node) string {
+	return nodeText(node, c.Source)
+}
+
+// Replace replaces the text of node with text.
+func (c *Context) Replace(node *tree_sitter.Node, text string, kind EditKind) {
+	start, end := node.StartPosition(), node.EndPosition()
+	c.edits = append(c.edits, Edit{
+		StartByte:   node.StartByte(),
+		EndByte:     node.EndByte(),
+		StartPoint:  Point{Row: start.Row, Column: start.Column},
+		EndPoint:    Point{Row: end.Row, Column: end.Column},
+		Original:    nodeText(node, c.Source),
+		Replacement: text,
+		Kind:        kind,
+		Rule:        c.rule,
+		Confidence:  HighConfidence,
+	})
+}
+
+// ReplaceRange replaces the source bytes [start, end) with text. An empty
+// text deletes the range.
+func (c *Context) ReplaceRange(start, end uint, text string, kind EditKind) {
+	c.replaceRange(start, end, text, kind, HighConfidence)
+}
+
+// replaceRange is ReplaceRange for an edit of the given confidence.
+func (c *Context) replaceRange(start, end uint, text string, kind EditKind, conf Confidence) {
+	c.edits = append(c.edits, Edit{
+		StartByte:   start,
+		EndByte:     end,
+		StartPoint:  c.lines.point(start),
+		EndPoint:    c.lines.point(end),
+		Original:    string(c.Source[start:end]),
+		Replacement: text,
+		Kind:        kind,
+		Rule:        c.rule,
+		Confidence:  conf,
+	})
+}
+
+// Insert inserts text at the byte offset.
+func (c *Context) Insert(offset uint, text string, kind EditKind) {
+	c.ReplaceRange(offset, offset, text, kind)
+}
+
+// ConflictError is returned by Run when two edits overlap, or insert
+// different text at the same offset, so they cannot both be applied.
+type ConflictError struct {
+	First, Second Edit
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting edits: %s edits bytes %d-%d (%q) and %s edits bytes %d-%d (%q)",
+		e.First.Rule, e.First.StartByte, e.First.EndByte, e.First.Replacement,
+		e.Second.Rule, e.Second.StartByte, e.Second.EndByte, e.Second.Replacement)
+}
+
+// Run parses source once, applies every rule to the tree and returns the
+// combined edits. Identical edits made by several rules are applied once;
+// any other overlap is reported as a *ConflictError.
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func Run(source []byte, lang Language, rules ...Rule) (*Result, error) {
+	return defaultMigrator.Run(source, lang, rules...)
+}
+
+// Run is like the package-level Run but reuses pooled parsers. Unless
+// WithVerify disabled it, the output is checked for new syntax errors.
+func (m *Migrator) Run(source []byte, lang Language, rules ...Rule) (*Result, error) {
+	if !m.verify {
+		return m.run(source, lang, rules, nil)
+	}
+	return m.run(source, lang, rules, func(res *Result) error {
+		return m.verifySyntax(res.Language, res)
+	})
+}
+
+// run is Run followed by a check of the result. A check error is
+// returned in place of the result.
+func (m *Migrator) run(source []byte, lang Language, rules []Rule, check func(*Result) error) (*Result, error) {
+	// An unsupported language is an error even for a source the
+	// prefilter would skip.
+	if _, err := getLanguage(lang); err != nil {
+		return nil, err
+	}
+	if m.skip(func() bool { return mayMatchAny(source, rules) }) {
+		return &Result{
+			Output:      applyReplacements(source, nil),
+			Prefiltered: true,
+			Language:    lang,
+		}, nil
+	}
+
+	p, err := m.parseSource(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer p.tree.Close()
+	tree, lang := p.tree, p.lang
+
+	lines := &lineIndex{source: source}
+	ctxs := make([]*Context, len(rules))
+	active := make([]int, len(rules))
+	for i, r := range rules {
+		ctxs[i] = &Context{Source: source, Language: lang, rule: r.Name(), lines: lines}
+		active[i] = i
+	}
+	walkRules(tree.RootNode(), rules, ctxs, active)
+
+	var edits []Edit
+	var unmigratable []Finding
+	for _, ctx := range ctxs {
+		if ctx.err != nil {
+			return nil, fmt.Errorf("%s: %w", ctx.rule, ctx.err)
+		}
+		edits = append(edits, ctx.edits...)
+		unmigratable = append(unmigratable, ctx.unmigratable...)
+	}
+	edits, err = mergeEdits(edits)
+	if err != nil {
+		return nil, err
+	}
+	edits, held := m.holdBack(edits)
+
+	res := &Result{
+		Output:       applyReplacements(source, edits),
+		Replacements: len(edits),
+		Edits:        edits,
+		Held:         held,
+		ParseErrors:  p.errs,
+		Language:     lang,
+		Unmigratable: unmigratable,
+	}
+	if check != nil {
+		if err := check(res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// holdBack splits edits into those at or above the minimum confidence
+// and those below it.
+func (m *Migrator) holdBack(edits []Edit) (apply, held []Edit) {
+	if m.minConfidence == LowConfidence {
+		return edits, nil
+	}
+	apply = edits[:0:0]
+	for _, e := range edits {
+		if e.Confidence >= m.minConfidence {
+			apply = append(apply, e)
+		} else {
+			held = append(held, e)
+		}
+	}
+	return apply, held
+}
+
+// mayMatchAny reports whether any rule could edit source. Rules that do
+// not implement PrefilterRule always could.
+func mayMatchAny(source []byte, rules []Rule) bool {
+	for _, r := range rules {
+		p, ok := r.(PrefilterRule)
+		if !ok || p.MayMatch(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkRules visits node and its descendants with the rules whose indices
+// are in active.
+func walkRules(node *tree_sitter.Node, rules []Rule, ctxs []*Context, active []int) {
+	next := active
+	copied := false
+	for j, i := range active {
+		keep := rules[i].Visit(ctxs[i], node)
+		switch {
+		case !keep && !copied:
+			next = append(make([]int, 0, len(active)), active[:j]...)
+			copied = true
+		case keep && copied:
+			next = append(next, i)
+		}
+	}
+	if len(next) == 0 {
+		return
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		walkRules(node.Child(i), rules, ctxs, next)
+	}
+}
+
+// mergeEdits sorts edits by position, drops exact duplicates and checks
+// that the rest can all be applied.
+func mergeEdits(edits []Edit) ([]Edit, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].StartByte != edits[j].StartByte {
+			return edits[i].StartByte < edits[j].StartByte
+		}
+		return edits[i].EndByte < edits[j].EndByte
+	})
+
+	merged := edits[:0]
+	for _, e := range edits {
+		if n := len(merged); n > 0 {
+			prev := merged[n-1]
+			if e.StartByte == prev.StartByte && e.EndByte == prev.EndByte && e.Replacement == prev.Replacement {
+				continue
+			}
+			sameInsertion := e.StartByte == e.EndByte && prev.StartByte == prev.EndByte && e.StartByte == prev.StartByte
+			if e.StartByte < prev.EndByte || sameInsertion {
+				return nil, &ConflictError{First: prev, Second: e}
+			}
+		}
+		merged = append(merged, e)
+	}
+	return merged, nil
+}
+
+// lineIndex converts byte offsets to points, building the table of line
+// starts on first use.
+type lineIndex struct {
+	source []byte
+	starts []uint
+}
+
+// point returns the row and byte column of offset.
+func (l *lineIndex) point(offset uint) Point {
+	if l.starts == nil {
+		l.starts = []uint{0}
+		for i, c := range l.source {
+			if c == '\n' {
+				l.starts = append(l.starts, uint(i+1))
+			}
+		}
+	}
+	row := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+	return Point{Row: uint(row), Column: offset - l.starts[row]}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Rule)
+)
+
+// Register makes a rule available by name through Lookup, typically from
+// an init function. It panics if a rule with the same name is already
+// registered.
+func Register(r Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name := r.Name()
+	if _, dup := registry[name]; dup {
+		panic("transform: Register called twice for rule " + name)
+	}
+	registry[name] = r
+}
+
+// Lookup returns the registered rule with the given name.
+func Lookup(name string) (Rule, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[name]
+	return r, ok
+}
+
+// RuleNames returns the names of all registered rules, sorted.
+func RuleNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(MigrateRule(AssertToWith))
+	Register(MigrateRule(WithToAssert))
+	Register(AddAttributesRule(AssertToWith, nil))
+	Register(StripRule())
+}
+
+// MigrateRule returns the rule that rewrites dir.From() to dir.To(), as
+// Migrate does. It is named after the direction, e.g. "assert-to-with".
+func MigrateRule(dir Direction) Rule {
+	return migrateRule{dir: dir}
+}
+
+type migrateRule struct {
+	dir Direction
+}
+
+func (r migrateRule) Name() string { return r.dir.String() }
+
+func (r migrateRule) MayMatch(source []byte) bool {
+	return mayContainKeyword(source, r.dir.From()) || mayContainComputedKey(source)
+}
+
+// Visit searches the whole tree with the precompiled matcher query when
+// called with its root, so it never needs to see the root's descendants.
+func (r migrateRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	var findings []Finding
+	ctx.fail(collectMigrations(node, ctx.Language, ctx.Source, r.dir, &findings))
+	for _, f := range findings {
+		ctx.replaceRange(f.StartByte, f.EndByte, r.dir.To(), f.Kind, f.Strategy.Confidence())
+	}
+	ctx.fail(collectComputedKeys(node, ctx.Language, ctx.Source, &ctx.unmigratable))
+	return false
+}
+
+// AddAttributesRule returns the rule that inserts dir.To() attribute
+// clauses into imports that have none, as AddImportAttributes does.
+// types is as for AddImportAttributes. The rule is named
+// "add-attributes".
+func AddAttributesRule(dir Direction, types map[string]string) Rule {
+	if types == nil {
+		types = DefaultAttributeTypes
+	}
+	return addAttributesRule{dir: dir, types: types}
+}
+
+type addAttributesRule struct {
+	dir   Direction
+	types map[string]string
+}
+
+func (r addAttributesRule) Name() string { return "add-attributes" }
+
+func (r addAttributesRule) MayMatch(source []byte) bool {
+	return mayNeedAttributes(source, r.types)
+}
+
+func (r addAttributesRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	f, ok := matchMissingAttributes(node, ctx.Source, r.dir.To(), r.types)
+	if !ok {
+		return true
+	}
+	ctx.Insert(f.StartByte, f.Insertion, f.Kind)
+	return false
+}
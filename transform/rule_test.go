@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// specifierRule rewrites module specifiers with the given prefix.
+type specifierRule struct {
+	from, to string
+}
+
+func (r specifierRule) Name() string { return "rewrite-specifier" }
+
+func (r specifierRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	if node.Kind() != "string_fragment" {
+		return true
+	}
+	if text := ctx.Text(node); strings.HasPrefix(text, r.from) {
+		ctx.Replace(node, r.to+strings.TrimPrefix(text, r.from), StaticImport)
+	}
+	return false
+}
+
+// wholeStatementRule replaces every import statement with a comment.
+type wholeStatementRule struct{}
+
+func (wholeStatementRule) Name() string { return "comment-out-imports" }
+
+func (wholeStatementRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	if node.Kind() == "import_statement" {
+		ctx.Replace(node, "/* removed */", StaticImport)
+		return false
+	}
+	return true
+}
+
+func TestRun_CombinesRules(t *testing.T) {
+	source := []byte("import a from '~/a.json' assert { type: 'json' };\nimport b from '~/b.json';\n")
+
+	result, err := Run(source, JavaScript,
+		MigrateRule(AssertToWith),
+		AddAttributesRule(AssertToWith, nil),
+		specifierRule{from: "~/", to: "./src/"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "import a from './src/a.json' with { type: 'json' };\nimport b from './src/b.json' with { type: 'json' };\n"
+	if got := string(result.Output); got != want {
+		t.Errorf("output mismatch\ngot:  %s\nwant: %s", got, want)
+	}
+
+	var rules []string
+	for _, e := range result.Edits {
+		rules = append(rules, e.Rule)
+	}
+	wantRules := "rewrite-specifier,assert-to-with,rewrite-specifier,add-attributes"
+	if got := strings.Join(rules, ","); got != wantRules {
+		t.Errorf("edit rules: got %s, want %s", got, wantRules)
+	}
+}
+
+// brokenMatcherRule runs a built-in rule with a language that has no
+// matcher, as if its query had failed to compile.
+type brokenMatcherRule struct{ Rule }
+
+func (r brokenMatcherRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	ctx.Language = Language(99)
+	return r.Rule.Visit(ctx, node)
+}
+
+func TestRun_MatcherError(t *testing.T) {
+	source := []byte("import a from './a.json' assert { type: 'json' };\n")
+
+	for _, rule := range []Rule{MigrateRule(AssertToWith), StripRule()} {
+		if _, err := Run(source, JavaScript, brokenMatcherRule{rule}); err == nil {
+			t.Errorf("%s: expected the matcher error, got a result", rule.Name())
+		}
+	}
+}
+
+func TestRun_Conflict(t *testing.T) {
+	source := []byte("import a from './a.json' assert { type: 'json' };\n")
+
+	_, err := Run(source, JavaScript, MigrateRule(AssertToWith), wholeStatementRule{})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a *ConflictError, got %v", err)
+	}
+	if conflict.First.Rule != "comment-out-imports" || conflict.Second.Rule != "assert-to-with" {
+		t.Errorf("unexpected conflict: %v", conflict)
+	}
+}
+
+func TestRun_DuplicateEditsApplyOnce(t *testing.T) {
+	source := []byte("import a from './a.json' assert { type: 'json' };\n")
+
+	result, err := Run(source, JavaScript, MigrateRule(AssertToWith), MigrateRule(AssertToWith))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Replacements != 1 {
+		t.Errorf("replacements: got %d, want 1", result.Replacements)
+	}
+}
+
+func TestRun_Prefilter(t *testing.T) {
+	m := NewMigrator()
+	defer m.Close()
+
+	source := []byte("import a from './a.js';\n")
+	result, err := m.Run(source, JavaScript, MigrateRule(AssertToWith))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Prefiltered {
+		t.Error("a source without `assert` should be prefiltered")
+	}
+
+	// A rule without MayMatch could edit anything, so the source is parsed.
+	result, err = m.Run(source, JavaScript, MigrateRule(AssertToWith), specifierRule{from: "./", to: "~/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Prefiltered || string(result.Output) != "import a from '~/a.js';\n" {
+		t.Errorf("unexpected result: prefiltered=%v output=%q", result.Prefiltered, result.Output)
+	}
+}
+
+func TestContext_ReplaceRangePoints(t *testing.T) {
+	source := []byte("// header\nimport a from './a.json';\n")
+
+	result, err := AddImportAttributes(source, JavaScript, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Edits) != 1 {
+		t.Fatalf("got %d edits, want 1", len(result.Edits))
+	}
+	if p := result.Edits[0].StartPoint; p != (Point{Row: 1, Column: 24}) {
+		t.Errorf("start point: got %+v, want {Row:1 Column:24}", p)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"assert-to-with", "with-to-assert", "add-attributes", "strip-attributes"} {
+		r, ok := Lookup(name)
+		if !ok {
+			t.Errorf("built-in rule %q is not registered", name)
+			continue
+		}
+		if r.Name() != name {
+			t.Errorf("Lookup(%q) returned rule named %q", name, r.Name())
+		}
+	}
+
+	Register(specifierRule{})
+	if _, ok := Lookup("rewrite-specifier"); !ok {
+		t.Error("registered rule not found")
+	}
+	names := RuleNames()
+	if !strings.Contains(strings.Join(names, ","), "rewrite-specifier") {
+		t.Errorf("RuleNames() = %v, missing rewrite-specifier", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate name should panic")
+		}
+	}()
+	Register(specifierRule{})
+}
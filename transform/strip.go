@@ -0,0 +1,289 @@
+package transform
+
+import (
+	"bytes"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// StripAttributes removes import assertion and import attribute clauses
+// entirely, for code that is only ever consumed by a bundler:
+//
+//	import data from './data.json' assert { type: 'json' };
+//	export { x } from './x.json' with { type: 'json' };
+//	const a = await import('./a.json', { with: { type: 'json' } });
+//	const b = await import('./b.json', { with: { type: 'json' }, signal });
+//
+// become
+//
+//	import data from './data.json';
+//	export { x } from './x.json';
+//	const a = await import('./a.json');
+//	const b = await import('./b.json', { signal });
+//
+// The whitespace before a static clause is removed with it. An import()
+// options object is removed along with its comma when the assertion is
+// its only property; otherwise only that property and one adjoining
+// comma are removed. Each removal is an Edit whose Replacement is empty.
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func StripAttributes(source []byte, lang Language) (*Result, error) {
+	return defaultMigrator.StripAttributes(source, lang)
+}
+
+// StripAttributes is like the package-level StripAttributes but reuses
+// pooled parsers.
+func (m *Migrator) StripAttributes(source []byte, lang Language) (*Result, error) {
+	return m.Run(source, lang, StripRule())
+}
+
+// StripRule returns the rule that removes attribute clauses, as
+// StripAttributes does. It is named "strip-attributes".
+func StripRule() Rule {
+	return stripRule{}
+}
+
+type stripRule struct{}
+
+func (stripRule) Name() string { return "strip-attributes" }
+
+func (stripRule) MayMatch(source []byte) bool {
+	return mayContainKeyword(source, "assert") || mayContainKeyword(source, "with")
+}
+
+// Visit searches the whole tree with the precompiled matcher query when
+// called with its root, so it never needs to see the root's descendants.
+func (stripRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	cands, err := candidates(node, ctx.Language, ctx.Source, "assert", "with")
+	if err != nil {
+		ctx.fail(err)
+		return false
+	}
+
+	var skip uint
+	for i := range cands {
+		c := &cands[i]
+		if c.node.StartByte() < skip {
+			continue
+		}
+
+		// All assertions in one import() options object are removed
+		// together, so that neighbouring removals never claim the same
+		// comma. Options of import types are left alone: TypeScript
+		// needs `resolution-mode` there whatever the keyword.
+		if c.options {
+			if isImportOptions(&c.node) {
+				if kind := importKind(c.node.Parent().Parent()); kind != ImportType {
+					for _, r := range stripOptionsRanges(&c.node, ctx.Source) {
+						ctx.ReplaceRange(r[0], r[1], "", kind)
+					}
+				}
+				skip = c.node.EndByte()
+			}
+			continue
+		}
+
+		for _, keyword := range []string{"assert", "with"} {
+			f, kw, ok := matchKeyword(&c.node, ctx.Source, keyword)
+			if !ok {
+				continue
+			}
+			if f.Strategy != DynamicImportProperty && f.Kind != ImportType {
+				start, end := stripRange(kw, ctx.Source)
+				ctx.replaceRange(start, end, "", f.Kind, f.Strategy.Confidence())
+			}
+			skip = c.node.EndByte()
+			break
+		}
+	}
+	return false
+}
+
+// stripRange returns the byte range to delete for a static clause
+// starting at keyword.
+func stripRange(keyword *tree_sitter.Node, source []byte) (start, end uint) {
+	start = clauseStart(keyword, source)
+
+	// A grammar that understood the clause has the object after the
+	// keyword.
+	next := keyword.NextSibling()
+	for next != nil && next.Kind() == "comment" {
+		next = next.NextSibling()
+	}
+	if next != nil && next.Kind() == "object" {
+		return start, next.EndByte()
+	}
+
+	// Otherwise the clause was recovered as ERROR nodes whose shape
+	// varies by grammar, so its extent is found in the text: the keyword,
+	// then a balanced `{ ... }`.
+	end = keyword.EndByte()
+	if i := skipSpace(source, end); i < uint(len(source)) && source[i] == '{' {
+		if close, ok := matchBrace(source, i); ok {
+			end = close
+		}
+	}
+	return start, end
+}
+
+// clauseStart returns where the removal of a static clause starting at
+// keyword begins: the end of the preceding node (normally the module
+// specifier), so the whitespace in between goes too. If a comment
+// precedes the clause, only the spaces and tabs before it are removed so
+// that a line comment cannot swallow what follows.
+func clauseStart(keyword *tree_sitter.Node, source []byte) uint {
+	anchor := keyword
+	for p := anchor.Parent(); p != nil && p.StartByte() == anchor.StartByte(); p = p.Parent() {
+		anchor = p
+	}
+	if prev := anchor.PrevSibling(); prev != nil && prev.Kind() != "comment" {
+		return prev.EndByte()
+	}
+	start := keyword.StartByte()
+	for start > 0 && (source[start-1] == ' ' || source[start-1] == '\t') {
+		start--
+	}
+	return start
+}
+
+// isImportOptions reports whether node is the options object passed as
+// the second argument of an import() call.
+func isImportOptions(node *tree_sitter.Node) bool {
+	if node.Kind() != "object" {
+		return false
+	}
+	args := node.Parent()
+	if args == nil || args.Kind() != "arguments" || args.NamedChildCount() < 2 {
+		return false
+	}
+	call := args.Parent()
+	if call == nil || call.Kind() != "call_expression" {
+		return false
+	}
+	fn := call.ChildByFieldName("function")
+	return fn != nil && fn.Kind() == "import" && args.NamedChild(1).StartByte() == node.StartByte()
+}
+
+// stripOptionsRanges returns the ranges to delete from an import()
+// options object: the whole object and its leading comma if it only
+// holds assertions, or else each run of consecutive assertion properties
+// together with the separators up to the next remaining property (or
+// from the previous one, for a run at the end).
+func stripOptionsRanges(object *tree_sitter.Node, source []byte) [][2]uint {
+	var props []*tree_sitter.Node
+	var strip []bool
+	n := 0
+	for i := uint(0); i < object.NamedChildCount(); i++ {
+		child := object.NamedChild(i)
+		if child.Kind() == "comment" {
+			continue
+		}
+		props = append(props, child)
+		strip = append(strip, isAttributesProperty(child, source))
+		if strip[len(strip)-1] {
+			n++
+		}
+	}
+
+	switch n {
+	case 0:
+		return nil
+	case len(props):
+		start := object.StartByte()
+		if comma := object.PrevSibling(); comma != nil && comma.Kind() == "," {
+			start = comma.StartByte()
+		}
+		return [][2]uint{{start, object.EndByte()}}
+	}
+
+	var ranges [][2]uint
+	for i := 0; i < len(props); i++ {
+		if !strip[i] {
+			continue
+		}
+		j := i
+		for j+1 < len(props) && strip[j+1] {
+			j++
+		}
+		if j+1 < len(props) {
+			ranges = append(ranges, [2]uint{props[i].StartByte(), props[j+1].StartByte()})
+		} else {
+			ranges = append(ranges, [2]uint{props[i-1].EndByte(), props[j].EndByte()})
+		}
+		i = j
+	}
+	return ranges
+}
+
+// isAttributesProperty reports whether prop is an `assert: ...` or
+// `with: ...` pair. The key may be quoted.
+func isAttributesProperty(prop *tree_sitter.Node, source []byte) bool {
+	if prop.Kind() != "pair" {
+		return false
+	}
+	key := prop.ChildByFieldName("key")
+	if key == nil {
+		return false
+	}
+	if key.Kind() != "property_identifier" {
+		if key = quotedKeyText(key); key == nil {
+			return false
+		}
+	}
+	text := nodeText(key, source)
+	return text == "assert" || text == "with"
+}
+
+// skipSpace returns the offset of the first non-whitespace byte at or
+// after i.
+func skipSpace(source []byte, i uint) uint {
+	for i < uint(len(source)) {
+		switch source[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// matchBrace returns the offset just past the `}` matching the `{` at
+// open, skipping over quoted strings and comments. It returns false if
+// the braces are unbalanced.
+func matchBrace(source []byte, open uint) (uint, bool) {
+	depth := 0
+	var quote byte
+	n := uint(len(source))
+	for i := open; i < n; i++ {
+		c := source[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '/' && i+1 < n && source[i+1] == '/':
+			for i < n && source[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && source[i+1] == '*':
+			end := bytes.Index(source[i+2:], []byte("*/"))
+			if end < 0 {
+				return 0, false
+			}
+			i += uint(end) + 3
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,139 @@
+package transform
+
+import "testing"
+
+func TestStripAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		lang  Language
+		count int
+	}{
+		{
+			name:  "static assert",
+			input: `import data from './data.json' assert { type: 'json' };`,
+			want:  `import data from './data.json';`,
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "static with",
+			input: `import data from './data.json' with { type: 'json' };`,
+			want:  `import data from './data.json';`,
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "re-exports",
+			input: "export { a } from './a.json' assert { type: 'json' };\nexport { b } from './b.json' with { type: 'json' };\n",
+			want:  "export { a } from './a.json';\nexport { b } from './b.json';\n",
+			lang:  JavaScript,
+			count: 2,
+		},
+		{
+			name:  "comment before clause is kept",
+			input: `import data from './data.json' /* config */ with { type: 'json' };`,
+			want:  `import data from './data.json' /* config */;`,
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "brace in a block comment",
+			input: "import a from './a.json' assert { type: 'json' /* } */ };\nconst b = 1;\n",
+			want:  "import a from './a.json';\nconst b = 1;\n",
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "brace in a block comment (TypeScript)",
+			input: "import a from './a.json' with { type: 'json' /* } */ };\nconst b = 1;\n",
+			want:  "import a from './a.json';\nconst b = 1;\n",
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "brace in a line comment",
+			input: "import a from './a.json' with { type: 'json' // }\n};\nconst b = 1;\n",
+			want:  "import a from './a.json';\nconst b = 1;\n",
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "brace in a line comment (TypeScript)",
+			input: "import a from './a.json' assert { type: 'json' // }\n};\nconst b = 1;\n",
+			want:  "import a from './a.json';\nconst b = 1;\n",
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "dynamic import with only an assertion",
+			input: `const d = await import('./d.json', { assert: { type: 'json' } });`,
+			want:  `const d = await import('./d.json');`,
+			lang:  JavaScript,
+			count: 1,
+		},
+		{
+			name:  "dynamic import keeps other options",
+			input: "const a = await import('./a.json', { with: { type: 'json' }, signal });\nconst b = await import('./b.json', { signal, with: { type: 'json' } });\n",
+			want:  "const a = await import('./a.json', { signal });\nconst b = await import('./b.json', { signal });\n",
+			lang:  TypeScript,
+			count: 2,
+		},
+		{
+			name:  "dynamic import with quoted keys",
+			input: "const a = await import('./a.json', { 'assert': { type: 'json' } });\nconst b = await import('./b.json', { signal, [\"with\"]: { type: 'json' } });\n",
+			want:  "const a = await import('./a.json');\nconst b = await import('./b.json', { signal });\n",
+			lang:  JavaScript,
+			count: 2,
+		},
+		{
+			name:  "export star (TypeScript)",
+			input: "export * from './a.json' with { type: 'json' };\nexport * as ns from './b.json' assert { type: 'json' };\n",
+			want:  "export * from './a.json';\nexport * as ns from './b.json';\n",
+			lang:  TypeScript,
+			count: 2,
+		},
+		{
+			name:  "export star (TSX)",
+			input: "export * from './a.json' with { type: 'json' };\nexport * as ns from './b.json' assert { type: 'json' };\n",
+			want:  "export * from './a.json';\nexport * as ns from './b.json';\n",
+			lang:  TSX,
+			count: 2,
+		},
+		{
+			name:  "import type options are kept",
+			input: "type A = import('pkg', { with: { 'resolution-mode': 'require' } }).A;\nlet b: typeof import('pkg', { assert: { 'resolution-mode': 'import' } });\nconst c = await import('./c.json', { with: { type: 'json' } });\n",
+			want:  "type A = import('pkg', { with: { 'resolution-mode': 'require' } }).A;\nlet b: typeof import('pkg', { assert: { 'resolution-mode': 'import' } });\nconst c = await import('./c.json');\n",
+			lang:  TypeScript,
+			count: 1,
+		},
+		{
+			name:  "nothing to strip",
+			input: "import a from './a.js';\nconst b = await import('./b.js');\n",
+			want:  "import a from './a.js';\nconst b = await import('./b.js');\n",
+			lang:  JavaScript,
+			count: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := StripAttributes([]byte(tt.input), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := string(result.Output); got != tt.want {
+				t.Errorf("output mismatch\ngot:  %s\nwant: %s", got, tt.want)
+			}
+			if result.Replacements != tt.count {
+				t.Errorf("replacements: got %d, want %d", result.Replacements, tt.count)
+			}
+			for _, e := range result.Edits {
+				if e.Replacement != "" || e.Original != tt.input[e.StartByte:e.EndByte] {
+					t.Errorf("edit %+v is not a removal of the original text", e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package transform
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// ParseError is a place where the grammar could not parse the source and
+// tree-sitter recovered: an ERROR node, which spans the text it skipped,
+// or a MISSING node, a zero-width token it assumed. Matches inside or
+// next to one rely on that recovery, and constructs it swallowed may not
+// be matched at all.
+type ParseError struct {
+	StartByte  uint
+	EndByte    uint
+	StartPoint Point
+	EndPoint   Point
+	// Missing is the kind of the token the parser assumed, such as ";",
+	// or empty for an ERROR node.
+	Missing string
+	// Attribute is set when the error goes away once the import
+	// attribute clauses are stripped from the source: the grammar does
+	// not understand a clause the matcher found, as with `assert` in the
+	// JavaScript grammar or attributes on re-exports. Other errors may
+	// hide imports the matcher could not find.
+	Attribute bool
+}
+
+// ParseErrors parses source and returns its parse errors, in source
+// order.
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func ParseErrors(source []byte, lang Language) ([]ParseError, error) {
+	return defaultMigrator.ParseErrors(source, lang)
+}
+
+// ParseErrors is like the package-level ParseErrors but reuses pooled
+// parsers.
+func (m *Migrator) ParseErrors(source []byte, lang Language) ([]ParseError, error) {
+	tree, err := m.parse(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	return m.parseErrors(tree.RootNode(), source, lang)
+}
+
+// parseErrors returns the parse errors under root, with Attribute set.
+func (m *Migrator) parseErrors(root *tree_sitter.Node, source []byte, lang Language) ([]ParseError, error) {
+	errs := parseErrors(root)
+	if len(errs) == 0 {
+		return nil, nil
+	}
+
+	ctx := &Context{Source: source, Language: lang, lines: &lineIndex{source: source}}
+	StripRule().Visit(ctx, root)
+	edits, err := mergeEdits(ctx.edits)
+	if err != nil || len(edits) == 0 {
+		return errs, err
+	}
+
+	stripped := applyReplacements(source, edits)
+	tree, err := m.parse(stripped, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	remaining := parseErrors(tree.RootNode())
+	for i := range errs {
+		errs[i].Attribute = true
+		for _, r := range remaining {
+			start, end := originalOffset(edits, r.StartByte), originalOffset(edits, r.EndByte)
+			if start <= errs[i].EndByte && errs[i].StartByte <= end {
+				errs[i].Attribute = false
+				break
+			}
+		}
+	}
+	return errs, nil
+}
+
+// originalOffset maps an offset in the output of edits back to the
+// source. Offsets inside an edit's replacement map to its start.
+func originalOffset(edits []Edit, off uint) uint {
+	for _, e := range edits {
+		if off <= e.StartByte {
+			break
+		}
+		if off-e.StartByte < uint(len(e.Replacement)) {
+			return e.StartByte
+		}
+		off = off - uint(len(e.Replacement)) + uint(len(e.Original))
+	}
+	return off
+}
+
+// parseErrors returns the outermost ERROR nodes and the MISSING nodes
+// under node, without Attribute set. Subtrees without errors are not
+// visited.
+func parseErrors(node *tree_sitter.Node) []ParseError {
+	var out []ParseError
+	collectParseErrors(node, &out)
+	return out
+}
+
+func collectParseErrors(node *tree_sitter.Node, out *[]ParseError) {
+	if !node.HasError() {
+		return
+	}
+	if node.IsError() || node.IsMissing() {
+		start, end := node.StartPosition(), node.EndPosition()
+		e := ParseError{
+			StartByte:  node.StartByte(),
+			EndByte:    node.EndByte(),
+			StartPoint: Point{Row: start.Row, Column: start.Column},
+			EndPoint:   Point{Row: end.Row, Column: end.Column},
+		}
+		if node.IsMissing() {
+			e.Missing = node.Kind()
+		}
+		*out = append(*out, e)
+		return
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		collectParseErrors(node.Child(i), out)
+	}
+}
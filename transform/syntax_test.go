@@ -0,0 +1,104 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		lang   Language
+		want   []ParseError
+	}{
+		{
+			name:   "clean",
+			source: `import data from './data.json' with { type: 'json' };`,
+			lang:   TypeScript,
+		},
+		{
+			name:   "assert clause in the JavaScript grammar",
+			source: `import a from './a.json' assert { type: 'json' };`,
+			lang:   JavaScript,
+			want: []ParseError{
+				{StartByte: 25, EndByte: 48, StartPoint: Point{Row: 0, Column: 25}, EndPoint: Point{Row: 0, Column: 48}, Attribute: true},
+			},
+		},
+		{
+			name:   "re-export clause and a real error",
+			source: "export { b } from './b.json' assert { type: 'json' };\nfoo(",
+			lang:   JavaScript,
+			want: []ParseError{
+				{StartByte: 0, EndByte: 37, StartPoint: Point{Row: 0, Column: 0}, EndPoint: Point{Row: 0, Column: 37}, Attribute: true},
+				{StartByte: 51, EndByte: 52, StartPoint: Point{Row: 0, Column: 51}, EndPoint: Point{Row: 0, Column: 52}, Attribute: true},
+				{StartByte: 54, EndByte: 58, StartPoint: Point{Row: 1, Column: 0}, EndPoint: Point{Row: 1, Column: 4}},
+			},
+		},
+		{
+			name:   "missing token",
+			source: "const a = 1;\nif (x { y }",
+			lang:   TypeScript,
+			want: []ParseError{
+				{StartByte: 18, EndByte: 18, StartPoint: Point{Row: 1, Column: 5}, EndPoint: Point{Row: 1, Column: 5}, Missing: ")"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseErrors([]byte(tt.source), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_HasError(t *testing.T) {
+	// The trailing call is cut off, so the grammar cannot parse the end
+	// of the file, but the import before it is still migrated.
+	source := []byte("import a from './a.json' assert { type: 'json' };\nfoo(")
+	result, err := MigrateAssertToWith(source, TypeScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.HasError() || len(result.ParseErrors) != 1 || result.ParseErrors[0].StartPoint.Row != 1 {
+		t.Errorf("ParseErrors = %+v, want one on the second line", result.ParseErrors)
+	}
+	if result.Replacements != 1 {
+		t.Errorf("Replacements = %d, want 1", result.Replacements)
+	}
+
+	scan, err := NewMigrator().Scan(source, TypeScript, AssertToWith)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scan.Findings) != 1 || !reflect.DeepEqual(scan.ParseErrors, result.ParseErrors) {
+		t.Errorf("Scan = %+v", scan)
+	}
+
+	clean, err := MigrateAssertToWith([]byte(`import a from './a.json' assert { type: 'json' };`), TypeScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if clean.HasError() {
+		t.Errorf("ParseErrors = %+v, want none", clean.ParseErrors)
+	}
+}
+
+func TestOriginalOffset(t *testing.T) {
+	// "abcdef" with "bc" deleted and "e" replaced by "XYZ" is "adXYZf".
+	edits := []Edit{
+		{StartByte: 1, EndByte: 3, Original: "bc"},
+		{StartByte: 4, EndByte: 5, Original: "e", Replacement: "XYZ"},
+	}
+	for out, want := range []uint{0, 1, 4, 4, 4, 5, 6} {
+		if got := originalOffset(edits, uint(out)); got != want {
+			t.Errorf("originalOffset(%d) = %d, want %d", out, got, want)
+		}
+	}
+}
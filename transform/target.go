@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Target is a runtime and minimum version that migrated code must run
+// on, such as Node.js 18.19 or Chrome 123.
+type Target struct {
+	// Runtime is "node", "deno" or "chrome".
+	Runtime string
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+// ParseTarget parses a target such as "node18.19", "node22", "deno1.40"
+// or "chrome123". Omitted version components are zero, so "node20"
+// means the first Node.js 20 release.
+func ParseTarget(s string) (Target, error) {
+	i := strings.IndexAny(s, "0123456789")
+	if i <= 0 {
+		return Target{}, fmt.Errorf("invalid target %q: want a runtime followed by a version, such as node20", s)
+	}
+
+	t := Target{Runtime: strings.ToLower(s[:i])}
+	if _, ok := compatTable[t.Runtime]; !ok {
+		return Target{}, fmt.Errorf("invalid target %q: unknown runtime %q (want node, deno or chrome)", s, t.Runtime)
+	}
+
+	parts := strings.Split(s[i:], ".")
+	if len(parts) > 3 {
+		return Target{}, fmt.Errorf("invalid target %q: too many version components", s)
+	}
+	var v version
+	for j, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Target{}, fmt.Errorf("invalid target %q: bad version component %q", s, p)
+		}
+		v[j] = n
+	}
+	t.Major, t.Minor, t.Patch = v[0], v[1], v[2]
+	return t, nil
+}
+
+// String returns the target in the form accepted by ParseTarget.
+func (t Target) String() string {
+	return fmt.Sprintf("%s%d.%d.%d", t.Runtime, t.Major, t.Minor, t.Patch)
+}
+
+// Support records which import attribute keywords a runtime accepts.
+type Support struct {
+	// Assert is true if legacy import assertions (`assert`) run.
+	Assert bool
+	// With is true if import attributes (`with`) run.
+	With bool
+}
+
+// Support looks t up in the compatibility table.
+func (t Target) Support() Support {
+	v := version{t.Major, t.Minor, t.Patch}
+	for _, r := range compatTable[t.Runtime] {
+		if !v.less(r.since) && (r.until == (version{}) || v.less(r.until)) {
+			return r.support
+		}
+	}
+	return Support{}
+}
+
+// Direction returns the migration that makes code run on t: AssertToWith
+// when t accepts `with` (preferring the standard syntax when both are
+// accepted), or WithToAssert when it accepts only `assert`. The second
+// result is false when t accepts neither keyword, in which case no
+// migration helps and any import attributes in the source are unsafe.
+func (t Target) Direction() (Direction, bool) {
+	s := t.Support()
+	switch {
+	case s.With:
+		return AssertToWith, true
+	case s.Assert:
+		return WithToAssert, true
+	default:
+		return AssertToWith, false
+	}
+}
+
+// version is a major.minor.patch triple.
+type version [3]int
+
+// less reports whether v sorts before w.
+func (v version) less(w version) bool {
+	for i := range v {
+		if v[i] != w[i] {
+			return v[i] < w[i]
+		}
+	}
+	return false
+}
+
+// compatRange is a half-open version range [since, until) with uniform
+// keyword support. A zero until is open-ended.
+type compatRange struct {
+	since   version
+	until   version
+	support Support
+}
+
+var (
+	supportAssert = Support{Assert: true}
+	supportWith   = Support{With: true}
+	supportBoth   = Support{Assert: true, With: true}
+)
+
+// compatTable lists, per runtime, the releases that accept each keyword.
+// Versions outside every range accept neither.
+//
+//   - Node.js added `assert` in 16.14 and 17.1, `with` in 18.20, 20.10
+//     and 21.0, and removed `assert` in 22.0.
+//   - Deno added `assert` in 1.17 and `with` in 1.37, and removed
+//     `assert` in 2.0.
+//   - Chrome (V8) added `assert` in 91 and `with` in 123, and removed
+//     `assert` in 126.
+var compatTable = map[string][]compatRange{
+	"node": {
+		{since: version{16, 14, 0}, until: version{17, 0, 0}, support: supportAssert},
+		{since: version{17, 1, 0}, until: version{18, 20, 0}, support: supportAssert},
+		{since: version{18, 20, 0}, until: version{19, 0, 0}, support: supportBoth},
+		{since: version{19, 0, 0}, until: version{20, 10, 0}, support: supportAssert},
+		{since: version{20, 10, 0}, until: version{22, 0, 0}, support: supportBoth},
+		{since: version{22, 0, 0}, support: supportWith},
+	},
+	"deno": {
+		{since: version{1, 17, 0}, until: version{1, 37, 0}, support: supportAssert},
+		{since: version{1, 37, 0}, until: version{2, 0, 0}, support: supportBoth},
+		{since: version{2, 0, 0}, support: supportWith},
+	},
+	"chrome": {
+		{since: version{91, 0, 0}, until: version{123, 0, 0}, support: supportAssert},
+		{since: version{123, 0, 0}, until: version{126, 0, 0}, support: supportBoth},
+		{since: version{126, 0, 0}, support: supportWith},
+	},
+}
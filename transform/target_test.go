@@ -0,0 +1,135 @@
+package transform
+
+import "testing"
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Target
+		wantErr bool
+	}{
+		{in: "node18.19", want: Target{Runtime: "node", Major: 18, Minor: 19}},
+		{in: "node20", want: Target{Runtime: "node", Major: 20}},
+		{in: "Node20.10.1", want: Target{Runtime: "node", Major: 20, Minor: 10, Patch: 1}},
+		{in: "deno1.40", want: Target{Runtime: "deno", Major: 1, Minor: 40}},
+		{in: "chrome123", want: Target{Runtime: "chrome", Major: 123}},
+		{in: "", wantErr: true},
+		{in: "20", wantErr: true},
+		{in: "node", wantErr: true},
+		{in: "safari17", wantErr: true},
+		{in: "node18.x", wantErr: true},
+		{in: "node1.2.3.4", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTarget(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTarget(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTarget(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTarget_Direction(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    Direction
+		wantOK  bool
+		support Support
+	}{
+		{"node14", AssertToWith, false, Support{}},
+		{"node16.14", WithToAssert, true, Support{Assert: true}},
+		{"node17.0", AssertToWith, false, Support{}},
+		{"node18.19", WithToAssert, true, Support{Assert: true}},
+		{"node18.20", AssertToWith, true, Support{Assert: true, With: true}},
+		{"node20", WithToAssert, true, Support{Assert: true}},
+		{"node20.10", AssertToWith, true, Support{Assert: true, With: true}},
+		{"node22", AssertToWith, true, Support{With: true}},
+		{"deno1.16", AssertToWith, false, Support{}},
+		{"deno1.36", WithToAssert, true, Support{Assert: true}},
+		{"deno1.40", AssertToWith, true, Support{Assert: true, With: true}},
+		{"deno2", AssertToWith, true, Support{With: true}},
+		{"chrome90", AssertToWith, false, Support{}},
+		{"chrome122", WithToAssert, true, Support{Assert: true}},
+		{"chrome123", AssertToWith, true, Support{Assert: true, With: true}},
+		{"chrome126", AssertToWith, true, Support{With: true}},
+	}
+	for _, tt := range tests {
+		target, err := ParseTarget(tt.target)
+		if err != nil {
+			t.Fatalf("ParseTarget(%q): %v", tt.target, err)
+		}
+		if got := target.Support(); got != tt.support {
+			t.Errorf("%s: Support() = %+v, want %+v", tt.target, got, tt.support)
+		}
+		got, ok := target.Direction()
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("%s: Direction() = %v, %v; want %v, %v", tt.target, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMigrator_FindUnsupported(t *testing.T) {
+	source := []byte(`import a from './a.json' with { type: 'json' };
+import b from './b.json' assert { type: 'json' };
+`)
+	m := NewMigrator()
+	defer m.Close()
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"node14", []string{"with", "assert"}},
+		{"node18.19", []string{"with"}},
+		{"node20.10", nil},
+		{"node22", []string{"assert"}},
+	}
+	for _, tt := range tests {
+		target, err := ParseTarget(tt.target)
+		if err != nil {
+			t.Fatalf("ParseTarget(%q): %v", tt.target, err)
+		}
+		findings, err := m.FindUnsupported(source, JavaScript, target)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.target, err)
+		}
+		if len(findings) != len(tt.want) {
+			t.Fatalf("%s: got %d findings, want %d", tt.target, len(findings), len(tt.want))
+		}
+		for i, f := range findings {
+			if f.Text != tt.want[i] {
+				t.Errorf("%s: finding %d is %q, want %q", tt.target, i, f.Text, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestMigrator_FindUnsupported_ImportType(t *testing.T) {
+	// Import types are erased before the code runs.
+	source := []byte(`type T = import('pkg', { assert: { 'resolution-mode': 'require' } }).T;
+const a = await import('./a.json', { assert: { type: 'json' } });
+`)
+	m := NewMigrator()
+	defer m.Close()
+
+	target, err := ParseTarget("node22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := m.FindUnsupported(source, TypeScript, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Kind != DynamicImport {
+		t.Errorf("got %+v, want only the dynamic import", findings)
+	}
+}
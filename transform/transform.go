@@ -9,6 +9,7 @@ package transform
 
 import (
 	"fmt"
+	"strings"
 	"unsafe"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -25,158 +26,405 @@ const (
 	TSX
 )
 
+// String returns the lower-case name of the language.
+func (l Language) String() string {
+	switch l {
+	case JavaScript:
+		return "javascript"
+	case TypeScript:
+		return "typescript"
+	case TSX:
+		return "tsx"
+	default:
+		return fmt.Sprintf("Language(%d)", int(l))
+	}
+}
+
+// ParseLanguage returns the Language whose String is s.
+func ParseLanguage(s string) (Language, error) {
+	for _, l := range []Language{JavaScript, TypeScript, TSX} {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown language %q (want javascript, typescript or tsx)", s)
+}
+
 // Result holds the output of a migration.
 type Result struct {
 	// Output is the transformed source code.
 	Output []byte
-	// Replacements is the number of `assert` → `with` substitutions made.
+	// Replacements is the number of keyword substitutions made.
 	Replacements int
+	// Edits describes each substitution, in source order.
+	Edits []Edit
+	// Prefiltered is true when the source was returned unchanged
+	// without parsing because it cannot contain an import assertion.
+	Prefiltered bool
+	// Held lists the edits below the Migrator's minimum confidence, in
+	// source order. They are not applied to Output or counted in
+	// Replacements; see WithMinConfidence.
+	Held []Edit
+	// ParseErrors lists where the grammar could not parse the source, in
+	// source order. Imports inside them may have been missed.
+	ParseErrors []ParseError
+	// Language is the grammar the source was parsed with. It differs
+	// from the one requested when WithGrammarFallback chose another.
+	Language Language
+	// Unmigratable lists the computed keys, such as `[key]`, in import()
+	// options objects, in source order. Whether they name an assertion
+	// is only known at run time, so they are left for manual review.
+	// Only MigrateRule, and so Migrate, reports them.
+	Unmigratable []Finding
 }
 
-// MigrateAssertToWith rewrites all import assertion keywords in source
-// from `assert` to `with`, returning the modified source and the
-// number of replacements made.
-//
-// The function parses source using the specified Language grammar,
-// walks the CST to find anonymous "assert" tokens that are children
-// of import_attribute (or similar) nodes, and replaces them with "with".
-//
-// Both static imports/exports and dynamic import() are handled:
-//
-//	import data from './data.json' assert { type: 'json' }
-//	export { default } from './data.json' assert { type: 'json' }
-//	const data = await import('./data.json', { assert: { type: 'json' } })
-func MigrateAssertToWith(source []byte, lang Language) (*Result, error) {
-	tsLang, err := getLanguage(lang)
-	if err != nil {
-		return nil, err
-	}
+// HasError reports whether the source had parse errors. A prefiltered
+// source was not parsed, so it never has.
+func (r *Result) HasError() bool {
+	return len(r.ParseErrors) > 0
+}
 
-	parser := tree_sitter.NewParser()
-	defer parser.Close()
+// ScanResult holds what Migrator.Scan found in a source.
+type ScanResult struct {
+	// Findings are as for FindDirection.
+	Findings []Finding
+	// ParseErrors, Language and Unmigratable are as for Result.
+	ParseErrors  []ParseError
+	Language     Language
+	Unmigratable []Finding
+	// Prefiltered is true when the source was not parsed because it
+	// cannot contain anything to find.
+	Prefiltered bool
+}
 
-	if err := parser.SetLanguage(tree_sitter.NewLanguage(tsLang)); err != nil {
-		return nil, fmt.Errorf("setting language: %w", err)
-	}
+// Point is a zero-based position in the source. Column is measured in
+// bytes, matching tree-sitter.
+type Point struct {
+	Row    uint
+	Column uint
+}
 
-	tree := parser.Parse(source, nil)
-	defer tree.Close()
+// EditKind identifies the kind of construct an edit was made in.
+type EditKind int
 
-	root := tree.RootNode()
-	if root == nil {
-		return nil, fmt.Errorf("parse returned nil root node")
+const (
+	// StaticImport is an `import ... from '...' assert { ... }` statement.
+	StaticImport EditKind = iota
+	// ReExport is an `export ... from '...' assert { ... }` statement.
+	ReExport
+	// DynamicImport is the options argument of an `import()` call.
+	DynamicImport
+	// ImportType is the options argument of a TypeScript import type,
+	// such as `import('pkg', { assert: { 'resolution-mode': 'require' } }).T`
+	// or `typeof import('pkg', { ... })`.
+	ImportType
+)
+
+// String returns the kebab-case name of the kind.
+func (k EditKind) String() string {
+	switch k {
+	case StaticImport:
+		return "static-import"
+	case ReExport:
+		return "re-export"
+	case DynamicImport:
+		return "dynamic-import"
+	case ImportType:
+		return "import-type"
+	default:
+		return fmt.Sprintf("EditKind(%d)", int(k))
 	}
+}
 
-	// Collect byte ranges that need replacement.
-	var replacements []replacement
-	collectReplacements(root, source, &replacements)
+// Direction selects which keyword a migration rewrites.
+type Direction int
 
-	// Build output with replacements applied.
-	output := applyReplacements(source, replacements)
+const (
+	// AssertToWith rewrites legacy import assertions (`assert`) to
+	// import attributes (`with`).
+	AssertToWith Direction = iota
+	// WithToAssert rewrites import attributes (`with`) back to legacy
+	// import assertions (`assert`).
+	WithToAssert
+)
 
-	return &Result{
-		Output:       output,
-		Replacements: len(replacements),
-	}, nil
+// From returns the keyword the direction rewrites.
+func (d Direction) From() string {
+	if d == WithToAssert {
+		return "with"
+	}
+	return "assert"
 }
 
-// DumpTree returns the S-expression representation of the parsed source.
-// Useful for debugging which node types the grammar produces for your code.
-func DumpTree(source []byte, lang Language) (string, error) {
-	tsLang, err := getLanguage(lang)
-	if err != nil {
-		return "", err
+// To returns the keyword the direction writes.
+func (d Direction) To() string {
+	if d == WithToAssert {
+		return "assert"
+	}
+	return "with"
+}
+
+// String returns the kebab-case name of the direction.
+func (d Direction) String() string {
+	return d.From() + "-to-" + d.To()
+}
+
+// Strategy identifies which matching rule located an assertion. The
+// strategies differ in how much they rely on tree-sitter error recovery,
+// which callers can use to gauge confidence in a match.
+type Strategy int
+
+const (
+	// AttributeNode matched an anonymous keyword token inside an
+	// import_attribute node: the grammar understood the syntax.
+	AttributeNode Strategy = iota
+	// ErrorInStatement matched the keyword at the start of an ERROR node
+	// inside an import/export statement (strategy 2a).
+	ErrorInStatement
+	// ErrorAtTopLevel matched the keyword following the source string in
+	// an ERROR node that replaced a whole import/export statement
+	// (strategy 2b).
+	ErrorAtTopLevel
+	// DynamicImportProperty matched the keyword as a property key, quoted
+	// or not, in the options argument of an import() call.
+	DynamicImportProperty
+	// MissingAttributes matched an import with no attributes whose
+	// specifier needs them; see AddImportAttributes.
+	MissingAttributes
+	// ComputedKey matched a computed key in the options argument of an
+	// import() call. It is never edited; see Result.Unmigratable.
+	ComputedKey
+)
+
+// String returns the kebab-case name of the strategy.
+func (s Strategy) String() string {
+	switch s {
+	case AttributeNode:
+		return "attribute-node"
+	case ErrorInStatement:
+		return "error-in-statement"
+	case ErrorAtTopLevel:
+		return "error-at-top-level"
+	case DynamicImportProperty:
+		return "dynamic-import-property"
+	case MissingAttributes:
+		return "missing-attributes"
+	case ComputedKey:
+		return "computed-key"
+	default:
+		return fmt.Sprintf("Strategy(%d)", int(s))
 	}
+}
 
-	parser := tree_sitter.NewParser()
-	defer parser.Close()
+// Confidence grades how sure a match is. Higher levels compare greater,
+// so an edit is applied under WithMinConfidence(min) if its Confidence
+// is >= min.
+type Confidence int
+
+const (
+	// LowConfidence matches rely on tree-sitter error recovery having
+	// replaced a whole statement with an ERROR node (ErrorAtTopLevel).
+	LowConfidence Confidence = iota
+	// MediumConfidence matches rely on error recovery inside a statement
+	// the grammar otherwise understood (ErrorInStatement).
+	MediumConfidence
+	// HighConfidence matches are on syntax the grammar parsed.
+	HighConfidence
+)
 
-	if err := parser.SetLanguage(tree_sitter.NewLanguage(tsLang)); err != nil {
-		return "", fmt.Errorf("setting language: %w", err)
+// String returns "low", "medium" or "high".
+func (c Confidence) String() string {
+	switch c {
+	case LowConfidence:
+		return "low"
+	case MediumConfidence:
+		return "medium"
+	case HighConfidence:
+		return "high"
+	default:
+		return fmt.Sprintf("Confidence(%d)", int(c))
 	}
+}
 
-	tree := parser.Parse(source, nil)
-	defer tree.Close()
+// ParseConfidence returns the Confidence whose String is s.
+func ParseConfidence(s string) (Confidence, error) {
+	for _, c := range []Confidence{LowConfidence, MediumConfidence, HighConfidence} {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown confidence %q (want low, medium or high)", s)
+}
 
-	root := tree.RootNode()
-	if root == nil {
-		return "", fmt.Errorf("parse returned nil root node")
+// Confidence returns how sure a match by s is.
+func (s Strategy) Confidence() Confidence {
+	switch s {
+	case ErrorAtTopLevel, ComputedKey:
+		return LowConfidence
+	case ErrorInStatement:
+		return MediumConfidence
+	default:
+		return HighConfidence
 	}
+}
+
+// Finding is an import assertion or import attribute keyword located by
+// Find, FindDirection or FindUnsupported.
+type Finding struct {
+	StartByte  uint
+	EndByte    uint
+	StartPoint Point
+	EndPoint   Point
+	// Text is the matched keyword as it appears in the source.
+	Text string
+	// Kind is the construct the assertion belongs to.
+	Kind EditKind
+	// Strategy is the matching rule that found the assertion.
+	Strategy Strategy
+	// Insertion is set for MissingAttributes findings to the attribute
+	// clause a migration inserts at StartByte. Text is empty for them.
+	Insertion string
+}
+
+// Edit is a single byte-range replacement made to the source. Offsets
+// and points refer to the original input, not the output.
+type Edit struct {
+	StartByte  uint
+	EndByte    uint
+	StartPoint Point
+	EndPoint   Point
+	// Original is the source text that was replaced.
+	Original string
+	// Replacement is the text written in its place.
+	Replacement string
+	// Kind is the construct the edit belongs to.
+	Kind EditKind
+	// Rule is the name of the Rule that made the edit.
+	Rule string
+	// Confidence is that of the match behind the edit. Edits made with
+	// the exported Context methods are HighConfidence.
+	Confidence Confidence
+}
 
-	return root.ToSexp(), nil
+// MigrateAssertToWith rewrites all import assertion keywords in source
+// from `assert` to `with`, returning the modified source and the
+// number of replacements made.
+//
+// The function parses source using the specified Language grammar,
+// finds anonymous "assert" tokens that are children of import_attribute
+// (or similar) nodes with a precompiled tree-sitter query, and replaces
+// them with "with".
+//
+// Both static imports/exports and dynamic import() are handled:
+//
+//	import data from './data.json' assert { type: 'json' }
+//	export { default } from './data.json' assert { type: 'json' }
+//	const data = await import('./data.json', { assert: { type: 'json' } })
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func MigrateAssertToWith(source []byte, lang Language) (*Result, error) {
+	return defaultMigrator.MigrateAssertToWith(source, lang)
+}
+
+// Find reports every legacy import assertion in source without
+// rewriting it. It uses the same matching logic as MigrateAssertToWith,
+// so each Finding corresponds to exactly one Edit that a migration
+// would make.
+func Find(source []byte, lang Language) ([]Finding, error) {
+	return defaultMigrator.Find(source, lang)
+}
+
+// MigrateWithToAssert is the inverse of MigrateAssertToWith: it rewrites
+// import attributes (`with`) back to legacy import assertions (`assert`)
+// for runtimes that only understand the older syntax, such as Node.js
+// 16 and 17. It covers the same static, re-export and dynamic import()
+// forms and makes the same formatting-preserving byte-range edits.
+//
+// It uses a shared default Migrator; see Migrator for pooling details.
+func MigrateWithToAssert(source []byte, lang Language) (*Result, error) {
+	return defaultMigrator.MigrateWithToAssert(source, lang)
 }
 
-// replacement records a byte range to be replaced with "with".
-type replacement struct {
-	start uint
-	end   uint
+// DumpTree returns the S-expression representation of the parsed source.
+// Useful for debugging which node types the grammar produces for your code.
+func DumpTree(source []byte, lang Language) (string, error) {
+	return defaultMigrator.DumpTree(source, lang)
 }
 
-// collectReplacements walks the CST and finds all "assert" tokens
-// that appear in import/export attribute positions.
-func collectReplacements(node *tree_sitter.Node, source []byte, out *[]replacement) {
-	if node == nil {
-		return
+// newFinding records node as a keyword matched by strategy.
+func newFinding(node *tree_sitter.Node, source []byte, kind EditKind, strategy Strategy) Finding {
+	start, end := node.StartPosition(), node.EndPosition()
+	return Finding{
+		StartByte:  node.StartByte(),
+		EndByte:    node.EndByte(),
+		StartPoint: Point{Row: start.Row, Column: start.Column},
+		EndPoint:   Point{Row: end.Row, Column: end.Column},
+		Text:       nodeText(node, source),
+		Kind:       kind,
+		Strategy:   strategy,
 	}
+}
 
+// matchKeyword reports whether node is, or directly holds, keyword in an
+// import/export attribute position, returning the finding and the
+// keyword's node. Descendants of a matching node need not be visited.
+func matchKeyword(node *tree_sitter.Node, source []byte, keyword string) (Finding, *tree_sitter.Node, bool) {
 	kind := node.Kind()
 
-	// Strategy 1: Look for anonymous "assert" token inside import_attribute
+	// Strategy 1: Look for an anonymous keyword token inside import_attribute
 	// or import_assertion nodes. Some grammar versions produce:
 	//   (import_statement
 	//     source: (string)
 	//     (import_attribute "assert" (object ...)))
-	if !node.IsNamed() && kind == "assert" {
+	if !node.IsNamed() && kind == keyword {
 		parent := node.Parent()
 		if parent != nil && isImportAttributeNode(parent.Kind()) {
-			*out = append(*out, replacement{
-				start: uint(node.StartByte()),
-				end:   uint(node.EndByte()),
-			})
-			return
+			return newFinding(node, source, statementKind(parent.Parent()), AttributeNode), node, true
 		}
 	}
 
-	// Strategy 2: The grammar may not recognize "assert" as a keyword and
-	// instead produce an ERROR node. Two sub-cases:
+	// Strategy 2: The grammar may not recognize the keyword in this
+	// position and instead produce an ERROR node. Depending on the
+	// grammar, the keyword is then an identifier ("assert" in JavaScript)
+	// or an anonymous keyword token ("with", or "assert" in TypeScript).
+	// Two sub-cases:
 	//
 	// 2a: ERROR inside import_statement/export_statement - the ERROR's
-	//     first child is an identifier "assert":
+	//     first child is the keyword:
 	//       (import_statement ... (ERROR (identifier "assert") "{" ...))
 	//
 	// 2b: For re-exports the entire statement may be an ERROR at the
-	//     top level, containing export_clause, string, then identifier "assert":
+	//     top level, containing export_clause, string, then the keyword:
 	//       (ERROR "export" (export_clause) "from" (string) (identifier "assert") ...)
 	if kind == "ERROR" {
 		parent := node.Parent()
 		if parent != nil && isImportOrExportStatement(parent.Kind()) {
 			// Case 2a
 			firstChild := node.Child(0)
-			if firstChild != nil && firstChild.Kind() == "identifier" {
-				text := nodeText(firstChild, source)
-				if text == "assert" {
-					*out = append(*out, replacement{
-						start: uint(firstChild.StartByte()),
-						end:   uint(firstChild.EndByte()),
-					})
-					return
-				}
+			if firstChild != nil && isKeywordNode(firstChild, source, keyword) {
+				return newFinding(firstChild, source, statementKind(parent), ErrorInStatement), firstChild, true
 			}
 		}
-		// Case 2b: top-level ERROR containing export/import structure
-		if hasExportOrImportChild(node) {
+		// Case 2b: top-level ERROR containing export/import structure.
+		// The TypeScript grammars parse `export * from '...' with {`
+		// and `export * as ns from '...' with {` as a statement labelled
+		// `export`, so the ERROR node only holds the rest:
+		//   (labeled_statement (statement_identifier "export") (ERROR "*" "from" (string) "with" ...))
+		//   (labeled_statement (statement_identifier "export") (ERROR (namespace_export ...) "from" (string) "with" ...))
+		reExportStar := isExportStarError(node, source)
+		if reExportStar || hasExportOrImportChild(node) {
 			for i := uint(0); i < uint(node.ChildCount()); i++ {
 				child := node.Child(i)
-				if child.Kind() == "identifier" && nodeText(child, source) == "assert" {
+				if isKeywordNode(child, source, keyword) {
 					// Verify it follows a string node (the source path)
 					if i > 0 {
 						prev := node.Child(i - 1)
 						if prev != nil && prev.Kind() == "string" {
-							*out = append(*out, replacement{
-								start: uint(child.StartByte()),
-								end:   uint(child.EndByte()),
-							})
-							return
+							kind := StaticImport
+							if reExportStar || hasExportChild(node) {
+								kind = ReExport
+							}
+							return newFinding(child, source, kind, ErrorAtTopLevel), child, true
 						}
 					}
 				}
@@ -184,26 +432,37 @@ func collectReplacements(node *tree_sitter.Node, source []byte, out *[]replaceme
 		}
 	}
 
-	// Strategy 3: For dynamic import(), the assertion might appear as
-	// a property name `assert` inside the options object:
+	// Strategy 3: For dynamic import(), the keyword might appear as
+	// a property name inside the options object:
 	//   import('./foo.json', { assert: { type: 'json' } })
-	if node.IsNamed() && isPropertyIdentifier(kind) {
+	// TypeScript import types share the shape, so they are told apart
+	// by their position:
+	//   type T = import('pkg', { assert: { 'resolution-mode': 'require' } }).T
+	//
+	// A quoted key is matched by its string_fragment, so the quotes are
+	// kept:
+	//   import('./foo.json', { 'assert': { type: 'json' } })
+	// An identifier in a computed key is a variable, not the key; see
+	// collectComputedKeys.
+	if node.IsNamed() && (isPropertyIdentifier(kind) || isQuotedKey(node)) && !isComputedKey(node.Parent()) {
 		text := nodeText(node, source)
-		if text == "assert" && isInsideDynamicImportOptions(node) {
-			*out = append(*out, replacement{
-				start: uint(node.StartByte()),
-				end:   uint(node.EndByte()),
-			})
-			return
+		if text == keyword {
+			if call := enclosingDynamicImport(node); call != nil {
+				return newFinding(node, source, importKind(call), DynamicImportProperty), node, true
+			}
 		}
 	}
 
-	// Recurse into children.
-	count := node.ChildCount()
-	for i := uint(0); i < uint(count); i++ {
-		child := node.Child(uint(i))
-		collectReplacements(child, source, out)
+	return Finding{}, nil, false
+}
+
+// isKeywordNode returns true if node spells keyword, either as an
+// identifier or as an anonymous keyword token.
+func isKeywordNode(node *tree_sitter.Node, source []byte, keyword string) bool {
+	if node.IsNamed() {
+		return node.Kind() == "identifier" && nodeText(node, source) == keyword
 	}
+	return node.Kind() == keyword
 }
 
 // hasExportOrImportChild returns true if the ERROR node contains an
@@ -221,6 +480,37 @@ func hasExportOrImportChild(node *tree_sitter.Node) bool {
 	return false
 }
 
+// isExportStarError returns true if the ERROR node follows an `export`
+// label and starts with `*` or `* as ns`, the rest of an `export * from`
+// statement.
+func isExportStarError(node *tree_sitter.Node, source []byte) bool {
+	prev := node.PrevSibling()
+	first := node.Child(0)
+	return prev != nil && prev.Kind() == "statement_identifier" && nodeText(prev, source) == "export" &&
+		first != nil && (first.Kind() == "*" || first.Kind() == "namespace_export")
+}
+
+// hasExportChild returns true if the ERROR node contains an
+// export_clause or an anonymous "export" token.
+func hasExportChild(node *tree_sitter.Node) bool {
+	for i := uint(0); i < uint(node.ChildCount()); i++ {
+		switch node.Child(i).Kind() {
+		case "export_clause", "export":
+			return true
+		}
+	}
+	return false
+}
+
+// statementKind classifies an import/export statement node. Anything
+// other than an export_statement is treated as a static import.
+func statementKind(node *tree_sitter.Node) EditKind {
+	if node != nil && node.Kind() == "export_statement" {
+		return ReExport
+	}
+	return StaticImport
+}
+
 // isImportAttributeNode returns true if the node kind represents an
 // import attribute/assertion clause. Different grammar versions may
 // use different names.
@@ -242,6 +532,47 @@ func isImportOrExportStatement(kind string) bool {
 	return false
 }
 
+// isQuotedKey returns true if node is the text of a string-literal
+// property key, as in `{ 'assert': ... }` or `{ ['assert']: ... }`.
+func isQuotedKey(node *tree_sitter.Node) bool {
+	if node.Kind() != "string_fragment" {
+		return false
+	}
+	key := node.Parent()
+	if key != nil && isComputedKey(key.Parent()) {
+		key = key.Parent()
+	}
+	if key == nil || key.Parent() == nil || key.Parent().Kind() != "pair" {
+		return false
+	}
+	k := key.Parent().ChildByFieldName("key")
+	if k == nil {
+		return false
+	}
+	frag := quotedKeyText(k)
+	return frag != nil && frag.Id() == node.Id()
+}
+
+// quotedKeyText returns the string_fragment spelling a string-literal
+// key, or nil if key is not one.
+func quotedKeyText(key *tree_sitter.Node) *tree_sitter.Node {
+	if isComputedKey(key) && key.NamedChildCount() == 1 {
+		key = key.NamedChild(0)
+	}
+	if key.Kind() != "string" || key.NamedChildCount() != 1 {
+		return nil
+	}
+	if frag := key.NamedChild(0); frag.Kind() == "string_fragment" {
+		return frag
+	}
+	return nil
+}
+
+// isComputedKey returns true if node is a computed property name.
+func isComputedKey(node *tree_sitter.Node) bool {
+	return node != nil && node.Kind() == "computed_property_name"
+}
+
 // isPropertyIdentifier returns true if the node kind is a property name.
 func isPropertyIdentifier(kind string) bool {
 	switch kind {
@@ -251,32 +582,63 @@ func isPropertyIdentifier(kind string) bool {
 	return false
 }
 
-// isInsideDynamicImportOptions walks up the tree to check if this
-// property identifier is inside a dynamic import()'s options argument.
-func isInsideDynamicImportOptions(node *tree_sitter.Node) bool {
+// enclosingDynamicImport walks up the tree to check if this property
+// identifier is inside a dynamic import()'s options argument, and
+// returns the import() call (or import type) if so.
+func enclosingDynamicImport(node *tree_sitter.Node) *tree_sitter.Node {
 	// Walk up looking for: pair -> object -> arguments -> call_expression
 	// where the call_expression's function is "import".
 	current := node.Parent()
 	depth := 0
 	for current != nil && depth < 6 {
 		kind := current.Kind()
-		if kind == "call_expression" || kind == "import" {
+		if kind == "call_expression" || kind == "import" || kind == "import_type" {
 			// Check if the function being called is "import"
 			fn := current.ChildByFieldName("function")
 			if fn != nil && fn.Kind() == "import" {
-				return true
+				return current
 			}
 			// Some grammars represent dynamic import differently
 			firstChild := current.Child(0)
 			if firstChild != nil && firstChild.Kind() == "import" {
-				return true
+				return current
 			}
-			return false
+			return nil
 		}
 		current = current.Parent()
 		depth++
 	}
-	return false
+	return nil
+}
+
+// importKind classifies an import() call found by enclosingDynamicImport.
+// The TypeScript grammars parse an import type as an import() call, so
+// it is recognised by appearing in a type: the call, and any member
+// accesses on it, sit directly in a type annotation, alias or query.
+func importKind(call *tree_sitter.Node) EditKind {
+	if call.Kind() == "import_type" {
+		return ImportType
+	}
+	n := call
+	for n.Parent() != nil && n.Parent().Kind() == "member_expression" {
+		n = n.Parent()
+	}
+	if p := n.Parent(); p != nil && isTypeContext(p.Kind()) {
+		return ImportType
+	}
+	return DynamicImport
+}
+
+// isTypeContext returns true if the node kind only holds types.
+func isTypeContext(kind string) bool {
+	switch kind {
+	case "type_annotation", "type_alias_declaration", "type_query", "type_arguments",
+		"constraint", "default_type", "opting_type_annotation", "omitting_type_annotation",
+		"adding_type_annotation", "asserts_annotation", "type_predicate_annotation":
+		return true
+	}
+	// union_type, array_type, lookup_type, parenthesized_type, ...
+	return strings.HasSuffix(kind, "_type")
 }
 
 // nodeText extracts the source text for a node.
@@ -289,28 +651,30 @@ func nodeText(node *tree_sitter.Node, source []byte) string {
 	return string(source[start:end])
 }
 
-// applyReplacements applies all collected replacements to the source,
-// producing a new byte slice. Replacements must be non-overlapping
-// and are applied in order of their start position.
-func applyReplacements(source []byte, replacements []replacement) []byte {
-	if len(replacements) == 0 {
+// applyReplacements applies all collected edits to the source,
+// producing a new byte slice. Edits must be non-overlapping and are
+// applied in order of their start position.
+func applyReplacements(source []byte, edits []Edit) []byte {
+	if len(edits) == 0 {
 		return append([]byte(nil), source...) // Return a copy
 	}
 
-	// Estimate capacity: original size + (len("with") - len("assert")) * count
-	est := len(source) + (4-6)*len(replacements)
+	// Estimate capacity: original size adjusted by each edit's size change.
+	est := len(source)
+	for _, e := range edits {
+		est += len(e.Replacement) - int(e.EndByte-e.StartByte)
+	}
 	if est < len(source) {
 		est = len(source)
 	}
 	result := make([]byte, 0, est)
 
 	lastOffset := uint(0)
-	for _, r := range replacements {
-		// Copy everything from last position to start of this replacement
-		result = append(result, source[lastOffset:r.start]...)
-		// Write "with" instead of "assert"
-		result = append(result, "with"...)
-		lastOffset = r.end
+	for _, e := range edits {
+		// Copy everything from last position to start of this edit
+		result = append(result, source[lastOffset:e.StartByte]...)
+		result = append(result, e.Replacement...)
+		lastOffset = e.EndByte
 	}
 
 	// Copy the remainder
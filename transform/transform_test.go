@@ -1,6 +1,7 @@
 package transform
 
 import (
+	"os"
 	"strings"
 	"testing"
 )
@@ -186,6 +187,27 @@ func TestMigrateAssertToWith_DynamicImport(t *testing.T) {
 			}, "\n"),
 			wantN: 2,
 		},
+		{
+			name:  "dynamic import with quoted keys",
+			input: "import('./a.json', { 'assert': { type: 'json' } });\nimport('./b.json', { \"assert\": { type: 'json' } });\nimport('./c.json', { ['assert']: { type: 'json' } });\n",
+			lang:  JavaScript,
+			want:  "import('./a.json', { 'with': { type: 'json' } });\nimport('./b.json', { \"with\": { type: 'json' } });\nimport('./c.json', { ['with']: { type: 'json' } });\n",
+			wantN: 3,
+		},
+		{
+			name:  "assert strings that are not option keys are not changed",
+			input: "import('assert');\nimport('./a.json', { type: 'assert' });\nconst o = { 'assert': true };\n",
+			lang:  TypeScript,
+			want:  "import('assert');\nimport('./a.json', { type: 'assert' });\nconst o = { 'assert': true };\n",
+			wantN: 0,
+		},
+		{
+			name:  "computed keys are not changed",
+			input: "import('./a.json', { [key]: { type: 'json' } });\nimport('./b.json', { [assert]: { type: 'json' } });\n",
+			lang:  JavaScript,
+			want:  "import('./a.json', { [key]: { type: 'json' } });\nimport('./b.json', { [assert]: { type: 'json' } });\n",
+			wantN: 0,
+		},
 		{
 			name:  "assert in non-import context is not changed",
 			input: `console.assert(true, 'should be true');`,
@@ -378,3 +400,345 @@ func TestDumpTree(t *testing.T) {
 		t.Errorf("S-expression should contain import_statement, got: %s", sexp)
 	}
 }
+
+func TestMigrateAssertToWith_Edits(t *testing.T) {
+	input := strings.Join([]string{
+		`import a from './a.json' assert { type: 'json' };`,
+		`export { b } from './b.json' assert { type: 'json' };`,
+		`const c = await import('./c.json', { assert: { type: 'json' } });`,
+	}, "\n")
+
+	result, err := MigrateAssertToWith([]byte(input), JavaScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		row, col uint
+		kind     EditKind
+	}{
+		{0, 25, StaticImport},
+		{1, 29, ReExport},
+		{2, 37, DynamicImport},
+	}
+
+	if len(result.Edits) != len(want) {
+		t.Fatalf("edit count: got %d, want %d", len(result.Edits), len(want))
+	}
+	if result.Replacements != len(result.Edits) {
+		t.Errorf("Replacements = %d, want len(Edits) = %d", result.Replacements, len(result.Edits))
+	}
+
+	for i, w := range want {
+		e := result.Edits[i]
+		if e.StartPoint.Row != w.row || e.StartPoint.Column != w.col {
+			t.Errorf("edit %d: start point got %d:%d, want %d:%d", i, e.StartPoint.Row, e.StartPoint.Column, w.row, w.col)
+		}
+		if e.EndPoint.Row != w.row || e.EndPoint.Column != w.col+uint(len("assert")) {
+			t.Errorf("edit %d: end point got %d:%d, want %d:%d", i, e.EndPoint.Row, e.EndPoint.Column, w.row, w.col+uint(len("assert")))
+		}
+		if e.Kind != w.kind {
+			t.Errorf("edit %d: kind got %s, want %s", i, e.Kind, w.kind)
+		}
+		if e.Original != "assert" || e.Replacement != "with" {
+			t.Errorf("edit %d: got %q -> %q, want \"assert\" -> \"with\"", i, e.Original, e.Replacement)
+		}
+		if got := input[e.StartByte:e.EndByte]; got != "assert" {
+			t.Errorf("edit %d: byte range covers %q, want \"assert\"", i, got)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		lang         Language
+		wantKind     EditKind
+		wantStrategy Strategy
+	}{
+		{
+			name:         "attribute node (TypeScript)",
+			input:        `import data from './data.json' assert { type: 'json' };`,
+			lang:         TypeScript,
+			wantKind:     StaticImport,
+			wantStrategy: AttributeNode,
+		},
+		{
+			name:         "ERROR inside import statement (JavaScript)",
+			input:        `import data from './data.json' assert { type: 'json' };`,
+			lang:         JavaScript,
+			wantKind:     StaticImport,
+			wantStrategy: ErrorInStatement,
+		},
+		{
+			name:         "top-level ERROR for re-export (JavaScript)",
+			input:        `export { default } from './data.json' assert { type: 'json' };`,
+			lang:         JavaScript,
+			wantKind:     ReExport,
+			wantStrategy: ErrorAtTopLevel,
+		},
+		{
+			name:         "dynamic import property",
+			input:        `const data = await import('./data.json', { assert: { type: 'json' } });`,
+			lang:         JavaScript,
+			wantKind:     DynamicImport,
+			wantStrategy: DynamicImportProperty,
+		},
+		{
+			name:         "import type (TypeScript)",
+			input:        `type T = import('pkg', { assert: { 'resolution-mode': 'require' } }).T;`,
+			lang:         TypeScript,
+			wantKind:     ImportType,
+			wantStrategy: DynamicImportProperty,
+		},
+		{
+			name:         "typeof import type (TSX)",
+			input:        `let data: typeof import('./data.json', { assert: { type: 'json' } });`,
+			lang:         TSX,
+			wantKind:     ImportType,
+			wantStrategy: DynamicImportProperty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := Find([]byte(tt.input), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(findings) != 1 {
+				t.Fatalf("finding count: got %d, want 1", len(findings))
+			}
+
+			f := findings[0]
+			if f.Kind != tt.wantKind {
+				t.Errorf("kind: got %s, want %s", f.Kind, tt.wantKind)
+			}
+			if f.Strategy != tt.wantStrategy {
+				t.Errorf("strategy: got %s, want %s", f.Strategy, tt.wantStrategy)
+			}
+			if f.Text != "assert" || tt.input[f.StartByte:f.EndByte] != "assert" {
+				t.Errorf("finding covers %q (text %q), want \"assert\"", tt.input[f.StartByte:f.EndByte], f.Text)
+			}
+
+			// Find must agree with the edits a migration would make.
+			result, err := MigrateAssertToWith([]byte(tt.input), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result.Edits) != 1 || result.Edits[0].StartByte != f.StartByte {
+				t.Errorf("Find and MigrateAssertToWith disagree: finding at %d, edits %+v", f.StartByte, result.Edits)
+			}
+		})
+	}
+}
+
+func TestMigrateWithToAssert(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lang  Language
+		want  string
+		wantN int
+	}{
+		{
+			name:  "static import",
+			input: `import data from './data.json' with { type: 'json' };`,
+			lang:  JavaScript,
+			want:  `import data from './data.json' assert { type: 'json' };`,
+			wantN: 1,
+		},
+		{
+			name:  "re-export (JavaScript)",
+			input: `export { default } from './data.json' with { type: 'json' };`,
+			lang:  JavaScript,
+			want:  `export { default } from './data.json' assert { type: 'json' };`,
+			wantN: 1,
+		},
+		{
+			name:  "star re-export (JavaScript)",
+			input: `export * from './data.json' with { type: 'json' };`,
+			lang:  JavaScript,
+			want:  `export * from './data.json' assert { type: 'json' };`,
+			wantN: 1,
+		},
+		{
+			name:  "re-export (TypeScript)",
+			input: `export { default } from './data.json' with { type: 'json' };`,
+			lang:  TypeScript,
+			want:  `export { default } from './data.json' assert { type: 'json' };`,
+			wantN: 1,
+		},
+		{
+			name:  "dynamic import",
+			input: `const data = await import('./data.json', { with: { type: 'json' } });`,
+			lang:  JavaScript,
+			want:  `const data = await import('./data.json', { assert: { type: 'json' } });`,
+			wantN: 1,
+		},
+		{
+			name:  "already using assert (no change)",
+			input: `import data from './data.json' assert { type: 'json' };`,
+			lang:  TypeScript,
+			want:  `import data from './data.json' assert { type: 'json' };`,
+			wantN: 0,
+		},
+		{
+			name:  "with statement is not changed",
+			input: "import a from './a.js';\nwith (obj) { a(); }",
+			lang:  JavaScript,
+			want:  "import a from './a.js';\nwith (obj) { a(); }",
+			wantN: 0,
+		},
+		{
+			name: "preserves formatting",
+			input: "import data from './data.json' with {\n  type: 'json'\n};\n" +
+				"await import('./x.json', {\n\twith: { type: 'json' },\n});\n",
+			lang: TSX,
+			want: "import data from './data.json' assert {\n  type: 'json'\n};\n" +
+				"await import('./x.json', {\n\tassert: { type: 'json' },\n});\n",
+			wantN: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MigrateWithToAssert([]byte(tt.input), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := string(result.Output)
+			if got != tt.want {
+				t.Errorf("output mismatch:\n  got:  %q\n  want: %q", got, tt.want)
+			}
+
+			if result.Replacements != tt.wantN {
+				t.Errorf("replacement count: got %d, want %d", result.Replacements, tt.wantN)
+			}
+
+			// Migrating forward again must restore the input.
+			back, err := MigrateAssertToWith(result.Output, tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantN > 0 && string(back.Output) != tt.input {
+				t.Errorf("round trip mismatch:\n  got:  %q\n  want: %q", back.Output, tt.input)
+			}
+		})
+	}
+}
+
+func TestMigrateAssertToWith_TypeScriptReExport(t *testing.T) {
+	input := strings.Join([]string{
+		`import a from './a.json' assert { type: 'json' };`,
+		`export { default as b } from './b.json' assert { type: 'json' };`,
+	}, "\n")
+	want := strings.Join([]string{
+		`import a from './a.json' with { type: 'json' };`,
+		`export { default as b } from './b.json' with { type: 'json' };`,
+	}, "\n")
+
+	result, err := MigrateAssertToWith([]byte(input), TypeScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(result.Output); got != want {
+		t.Errorf("output mismatch:\n  got:  %q\n  want: %q", got, want)
+	}
+	if len(result.Edits) != 2 || result.Edits[1].Kind != ReExport {
+		t.Errorf("expected a static import and a re-export edit, got %+v", result.Edits)
+	}
+}
+
+// TestMigrateAssertToWith_TypeScriptExportStar covers the TypeScript
+// grammars parsing `export *` with a clause as a labelled statement.
+func TestMigrateAssertToWith_TypeScriptExportStar(t *testing.T) {
+	input := strings.Join([]string{
+		`export * from './a.json' assert { type: 'json' };`,
+		`export * as ns from './b.json' assert { type: 'json' };`,
+	}, "\n")
+	want := strings.Join([]string{
+		`export * from './a.json' with { type: 'json' };`,
+		`export * as ns from './b.json' with { type: 'json' };`,
+	}, "\n")
+
+	for _, lang := range []Language{TypeScript, TSX} {
+		result, err := MigrateAssertToWith([]byte(input), lang)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", lang, err)
+		}
+		if got := string(result.Output); got != want {
+			t.Errorf("%s: output mismatch:\n  got:  %q\n  want: %q", lang, got, want)
+		}
+		for _, e := range result.Edits {
+			if e.Kind != ReExport {
+				t.Errorf("%s: expected re-export edits, got %+v", lang, e)
+			}
+		}
+		if len(result.Edits) != 2 {
+			t.Errorf("%s: got %d edits, want 2", lang, len(result.Edits))
+		}
+	}
+}
+
+func TestMigrateAssertToWith_ImportType(t *testing.T) {
+	input := strings.Join([]string{
+		`type A = import("pkg", { assert: { "resolution-mode": "require" } }).TypeFromRequire;`,
+		`export type B = typeof import("pkg", { assert: { "resolution-mode": "import" } });`,
+		`export interface C { c: import("pkg", { assert: { "resolution-mode": "import" } }).ns.C }`,
+		`declare function d(): import("pkg", { assert: { "resolution-mode": "require" } }).D | null;`,
+		`const e = await import("./e.json", { assert: { type: "json" } });`,
+	}, "\n")
+	want := strings.ReplaceAll(input, "{ assert:", "{ with:")
+	kinds := []EditKind{ImportType, ImportType, ImportType, ImportType, DynamicImport}
+
+	for _, lang := range []Language{TypeScript, TSX} {
+		result, err := MigrateAssertToWith([]byte(input), lang)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", lang, err)
+		}
+		if got := string(result.Output); got != want {
+			t.Errorf("%s: output mismatch:\n  got:  %q\n  want: %q", lang, got, want)
+		}
+		if len(result.Edits) != len(kinds) {
+			t.Fatalf("%s: got %d edits, want %d", lang, len(result.Edits), len(kinds))
+		}
+		for i, e := range result.Edits {
+			if e.Kind != kinds[i] {
+				t.Errorf("%s: edit %d kind: got %s, want %s", lang, i, e.Kind, kinds[i])
+			}
+		}
+
+		// The reverse migration leaves import types on `with`, which
+		// TypeScript 5.3 and later expect.
+		back, err := MigrateWithToAssert(result.Output, lang)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", lang, err)
+		}
+		wantBack := strings.Replace(want, `import("./e.json", { with:`, `import("./e.json", { assert:`, 1)
+		if string(back.Output) != wantBack || len(back.Edits) != 1 || back.Edits[0].Kind != DynamicImport {
+			t.Errorf("%s: reverse migration mismatch:\n  got:  %q (%d edits)\n  want: %q", lang, back.Output, len(back.Edits), wantBack)
+		}
+	}
+}
+
+func TestMigrateAssertToWith_Testdata(t *testing.T) {
+	input, err := os.ReadFile("../testdata/sample.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../testdata/sample.expected.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MigrateAssertToWith(input, TypeScript)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(result.Output) != string(want) {
+		t.Errorf("output does not match sample.expected.ts:\n%s", result.Output)
+	}
+}
@@ -0,0 +1,110 @@
+package transform
+
+import "fmt"
+
+// VerifyError is returned by a migration whose output fails
+// verification (see WithVerify). The output is discarded, so the caller
+// should leave the source as it was.
+type VerifyError struct {
+	// ErrorsBefore and ErrorsAfter count the parse errors in the source
+	// and in the output.
+	ErrorsBefore, ErrorsAfter int
+	// Edit, if non-nil, is an edit whose keyword the output's tree does
+	// not have in an import attribute position.
+	Edit *Edit
+}
+
+func (e *VerifyError) Error() string {
+	if e.Edit != nil {
+		return fmt.Sprintf("verification failed: %q at %d:%d is not an import attribute after migration",
+			e.Edit.Replacement, e.Edit.StartPoint.Row+1, e.Edit.StartPoint.Column+1)
+	}
+	return fmt.Sprintf("verification failed: migration increased syntax errors from %d to %d",
+		e.ErrorsBefore, e.ErrorsAfter)
+}
+
+// verifySyntax re-parses res.Output, the result of running rules over a
+// source parsed with lang, and checks that it has no more parse errors
+// than the source outside attribute clauses. Errors inside them are left
+// out, as the JavaScript grammar fails on every `assert` clause, so they
+// come and go with migrations and -strip.
+func (m *Migrator) verifySyntax(lang Language, res *Result) error {
+	if len(res.Edits) == 0 {
+		return nil
+	}
+
+	out, err := m.parse(res.Output, lang)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	errs, err := m.parseErrors(out.RootNode(), res.Output, lang)
+	if err != nil {
+		return err
+	}
+	before, after := countSyntaxErrors(res.ParseErrors), countSyntaxErrors(errs)
+	if after > before {
+		return &VerifyError{ErrorsBefore: before, ErrorsAfter: after}
+	}
+	return nil
+}
+
+// countSyntaxErrors counts the errors that are not in attribute clauses.
+func countSyntaxErrors(errs []ParseError) int {
+	n := 0
+	for _, e := range errs {
+		if !e.Attribute {
+			n++
+		}
+	}
+	return n
+}
+
+// verifyOutput re-parses res.Output, which migrated a source parsed with
+// lang to dir.To(), and checks that the migration did not break the syntax:
+// the output must have no more parse errors than the source,
+// and the matcher must find every keyword written in an import attribute
+// position of the output's tree. The grammars parse attributes on
+// re-exports as ERROR nodes, so keywords the matcher finds inside them
+// count too; a bad match shows up as extra errors instead.
+func (m *Migrator) verifyOutput(lang Language, dir Direction, res *Result) error {
+	// The JavaScript grammar cannot parse `assert` clauses at all.
+	if len(res.Edits) == 0 || (dir == WithToAssert && lang == JavaScript) {
+		return nil
+	}
+
+	out, err := m.parse(res.Output, lang)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	before, after := len(res.ParseErrors), len(parseErrors(out.RootNode()))
+	if after > before {
+		return &VerifyError{ErrorsBefore: before, ErrorsAfter: after}
+	}
+
+	var findings []Finding
+	if err := collectFindings(out.RootNode(), lang, res.Output, dir.To(), &findings); err != nil {
+		return err
+	}
+	parsed := make(map[uint]bool, len(findings))
+	for _, f := range findings {
+		parsed[f.StartByte] = true
+	}
+
+	// Edits are sorted and disjoint, so each one moves the rest of the
+	// output by the difference in length.
+	var delta int
+	for i := range res.Edits {
+		e := &res.Edits[i]
+		if kw := indexWord([]byte(e.Replacement), dir.To(), 0); kw >= 0 {
+			if !parsed[uint(int(e.StartByte)+delta+kw)] {
+				return &VerifyError{ErrorsBefore: before, ErrorsAfter: after, Edit: e}
+			}
+		}
+		delta += len(e.Replacement) - int(e.EndByte-e.StartByte)
+	}
+	return nil
+}
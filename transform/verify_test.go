@@ -0,0 +1,146 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestVerifyOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// output replaces the first `assert` in source with replacement.
+		replacement string
+		wantEdit    bool
+		wantErrors  bool
+	}{
+		{
+			name:        "migrated clause",
+			source:      `import data from './data.json' assert { type: 'json' };`,
+			replacement: "with",
+		},
+		{
+			name:        "re-export stays an ERROR node",
+			source:      `export { default } from './data.json' assert { type: 'json' };`,
+			replacement: "with",
+		},
+		{
+			name:        "keyword outside an attribute",
+			source:      `console.assert(ok);`,
+			replacement: "with",
+			wantEdit:    true,
+		},
+		{
+			name:        "output has new syntax errors",
+			source:      `import data from './data.json' assert { type: 'json' };`,
+			replacement: "with {",
+			wantErrors:  true,
+		},
+	}
+
+	m := NewMigrator()
+	defer m.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := []byte(tt.source)
+			tree, err := m.parse(source, TypeScript)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			defer tree.Close()
+
+			start := uint(strings.Index(tt.source, "assert"))
+			edit := Edit{StartByte: start, EndByte: start + 6, Original: "assert", Replacement: tt.replacement}
+			res := &Result{
+				Output:       applyReplacements(source, []Edit{edit}),
+				Replacements: 1,
+				Edits:        []Edit{edit},
+				ParseErrors:  parseErrors(tree.RootNode()),
+			}
+
+			err = m.verifyOutput(TypeScript, AssertToWith, res)
+			var verr *VerifyError
+			if !tt.wantEdit && !tt.wantErrors {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a *VerifyError", err)
+			}
+			if got := verr.Edit != nil; got != tt.wantEdit {
+				t.Errorf("Edit set = %v, want %v (%v)", got, tt.wantEdit, err)
+			}
+			if got := verr.ErrorsAfter > verr.ErrorsBefore; got != tt.wantErrors {
+				t.Errorf("errors increased = %v, want %v (%v)", got, tt.wantErrors, err)
+			}
+		})
+	}
+}
+
+func TestMigrate_VerifyDisabled(t *testing.T) {
+	// The JavaScript grammar cannot parse `assert` clauses, so reverse
+	// migrations in it are never verified.
+	src := []byte(`import data from './data.json' with { type: 'json' };`)
+	for _, m := range []*Migrator{NewMigrator(), NewMigrator(WithVerify(false))} {
+		result, err := m.MigrateWithToAssert(src, JavaScript)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := `import data from './data.json' assert { type: 'json' };`; string(result.Output) != want {
+			t.Errorf("got %q, want %q", result.Output, want)
+		}
+		m.Close()
+	}
+}
+
+// truncateRule cuts the options off every dynamic import, leaving an
+// unclosed call.
+type truncateRule struct{}
+
+func (truncateRule) Name() string { return "truncate-import" }
+
+func (truncateRule) Visit(ctx *Context, node *tree_sitter.Node) bool {
+	if node.Kind() == "arguments" && node.NamedChildCount() == 2 {
+		ctx.ReplaceRange(node.NamedChild(0).EndByte(), node.EndByte(), "", DynamicImport)
+		return false
+	}
+	return true
+}
+
+func TestRun_Verify(t *testing.T) {
+	src := []byte("const a = await import('./a.json', { assert: { type: 'json' } });\n")
+
+	m := NewMigrator()
+	defer m.Close()
+	_, err := m.Run(src, JavaScript, truncateRule{})
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *VerifyError, got %v", err)
+	}
+	unverified := NewMigrator(WithVerify(false))
+	defer unverified.Close()
+	if _, err := unverified.Run(src, JavaScript, truncateRule{}); err != nil {
+		t.Errorf("WithVerify(false): unexpected error: %v", err)
+	}
+
+	// Errors inside attribute clauses are not counted, so stripping the
+	// clauses the grammars fail on passes.
+	tests := []struct {
+		lang   Language
+		source string
+	}{
+		{JavaScript, "import a from './a.json' assert { type: 'json' };\n"},
+		{TypeScript, "export * from './data.json' with { type: 'json' };\n"},
+	}
+	for _, tt := range tests {
+		if _, err := m.Run([]byte(tt.source), tt.lang, StripRule()); err != nil {
+			t.Errorf("%s: strip %q: unexpected error: %v", tt.lang, tt.source, err)
+		}
+	}
+}